@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"luma-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	Auth *service.AuthService
+}
+
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var input struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rawKey, key, err := h.Auth.CreateAPIKey(c.GetString("email"), input.Name, input.Scopes)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidScope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"key":     rawKey,
+		"api_key": key,
+		"message": "Store this key now; it will not be shown again.",
+	})
+}
+
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.Auth.ListAPIKeys(c.GetString("email"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	err := h.Auth.RevokeAPIKey(c.GetString("email"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"luma-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConversationHandler struct {
+	Service *service.ConversationService
+}
+
+func (h *ConversationHandler) ListConversations(c *gin.Context) {
+	conversations, err := h.Service.List(c.GetString("email"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving conversations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conversations": conversations})
+}
+
+func (h *ConversationHandler) CreateConversation(c *gin.Context) {
+	var input struct {
+		Title string `json:"title"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	conversation, err := h.Service.Create(c.GetString("email"), input.Title)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating conversation"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, conversation)
+}
+
+func (h *ConversationHandler) RenameConversation(c *gin.Context) {
+	var input struct {
+		Title string `json:"title"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(input.Title) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Title must not be empty"})
+		return
+	}
+
+	err := h.Service.Rename(c.GetString("email"), c.Param("id"), input.Title)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this conversation"})
+		case errors.Is(err, service.ErrConversationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error renaming conversation"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation renamed"})
+}
+
+func (h *ConversationHandler) DeleteConversation(c *gin.Context) {
+	err := h.Service.Delete(c.GetString("email"), c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this conversation"})
+		case errors.Is(err, service.ErrConversationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting conversation"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation deleted"})
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"luma-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxDatasetSize       = 10 << 20
+	maxReportedRowErrors = 100
+)
+
+type DatasetHandler struct {
+	Service *service.DatasetService
+}
+
+func (h *DatasetHandler) UploadDataset(c *gin.Context) {
+	email := c.GetString("email")
+	if email == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Email not found in token"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file not provided in 'file' field"})
+		return
+	}
+
+	if fileHeader.Size == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Uploaded file is empty"})
+		return
+	}
+
+	if fileHeader.Size > maxDatasetSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Uploaded file exceeds the 10 MB limit"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error opening uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading uploaded file"})
+		return
+	}
+
+	dataset, report, rowErrors, err := h.Service.UploadDataset(email, c.GetString("household_id"), fileHeader.Filename, string(data))
+	if len(report.RejectedRows) > maxReportedRowErrors {
+		report.RejectedRows = report.RejectedRows[:maxReportedRowErrors]
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidDataset) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "report": report})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving dataset"})
+		return
+	}
+
+	if len(rowErrors) > maxReportedRowErrors {
+		rowErrors = rowErrors[:maxReportedRowErrors]
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "Dataset uploaded successfully",
+		"name":        dataset.Name,
+		"rows":        dataset.Rows,
+		"schema":      dataset.Schema,
+		"report":      report,
+		"row_errors":  rowErrors,
+		"uploaded_at": dataset.UploadedAt,
+	})
+}
+
+func (h *DatasetHandler) GetDataset(c *gin.Context) {
+	dataset, err := h.Service.GetDataset(c.GetString("email"), c.GetString("household_id"))
+	if err != nil {
+		if errors.Is(err, service.ErrDatasetNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No dataset uploaded yet"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error loading dataset"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":        dataset.Name,
+		"rows":        dataset.Rows,
+		"schema":      dataset.Schema,
+		"uploaded_at": dataset.UploadedAt,
+	})
+}
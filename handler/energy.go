@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"luma-backend/model"
+	"luma-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EnergyHandler struct {
+	Service *service.EnergyService
+	Costs   *service.CostService
+}
+
+func (h *EnergyHandler) GetSeries(c *gin.Context) {
+	from, ok := queryTime(c, "from", false)
+	if !ok {
+		return
+	}
+	to, ok := queryTime(c, "to", true)
+	if !ok {
+		return
+	}
+
+	series, err := h.Service.Series(c.GetString("email"), c.GetString("household_id"), service.SeriesQuery{
+		GroupBy:  c.Query("group_by"),
+		Interval: c.Query("interval"),
+		From:     from,
+		To:       to,
+	})
+	if err != nil {
+		energyError(c, err, "Error computing energy series")
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+func (h *EnergyHandler) GetTariff(c *gin.Context) {
+	tariff, err := h.Costs.GetTariff(c.GetString("email"), c.GetString("household_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error loading tariff"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tariff)
+}
+
+func (h *EnergyHandler) UpdateTariff(c *gin.Context) {
+	var input model.Tariff
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tariff, err := h.Costs.SaveTariff(c.GetString("email"), c.GetString("household_id"), input)
+	if err != nil {
+		energyError(c, err, "Error saving tariff")
+		return
+	}
+
+	c.JSON(http.StatusOK, tariff)
+}
+
+func (h *EnergyHandler) GetCost(c *gin.Context) {
+	from, ok := queryTime(c, "from", false)
+	if !ok {
+		return
+	}
+	to, ok := queryTime(c, "to", true)
+	if !ok {
+		return
+	}
+
+	report, err := h.Costs.Report(c.GetString("email"), c.GetString("household_id"), from, to, c.Query("rows") == "true")
+	if err != nil {
+		energyError(c, err, "Error computing energy cost")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *EnergyHandler) GetForecast(c *gin.Context) {
+	query := service.ForecastQuery{
+		GroupBy: c.Query("group_by"),
+		Horizon: c.Query("horizon"),
+		Method:  c.Query("method"),
+	}
+
+	for name, target := range map[string]**float64{"temperature": &query.Temperature, "people": &query.People} {
+		if raw := c.Query(name); raw != "" {
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "'" + name + "' must be a number"})
+				return
+			}
+			*target = &value
+		}
+	}
+	if raw, ok := c.GetQuery("level"); ok {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || value <= 0 || value >= 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'level' must be a number between 0 and 1"})
+			return
+		}
+		query.Level = &value
+	}
+
+	forecast, err := h.Service.Forecast(c.GetString("email"), c.GetString("household_id"), query)
+	if err != nil {
+		energyError(c, err, "Error forecasting consumption")
+		return
+	}
+
+	c.JSON(http.StatusOK, forecast)
+}
+
+// queryTime parses a date (2023-01-31) or RFC 3339 timestamp. Dataset times
+// carry no zone, so everything is compared as UTC wall-clock time. A bare
+// date used as an upper bound includes that whole day.
+func queryTime(c *gin.Context, name string, endOfDay bool) (time.Time, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return time.Time{}, true
+	}
+
+	if t, err := time.Parse("2006-01-02", raw); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC), true
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{"error": "'" + name + "' must be a date (YYYY-MM-DD) or an RFC 3339 timestamp"})
+	return time.Time{}, false
+}
+
+func energyError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrDatasetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "No dataset uploaded yet"})
+	case errors.Is(err, service.ErrNoEnergyData):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "The dataset needs Date and Energy_Consumption columns for this view"})
+	case errors.Is(err, service.ErrInvalidSeriesQuery), errors.Is(err, service.ErrInvalidTariff), errors.Is(err, service.ErrInvalidForecastQuery):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestQueryTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		endOfDay bool
+		want     time.Time
+		ok       bool
+	}{
+		{"missing", "", false, time.Time{}, true},
+		{"date", "2023-01-05", false, time.Date(2023, time.January, 5, 0, 0, 0, 0, time.UTC), true},
+		{"date as end of range includes the day", "2023-01-05", true, time.Date(2023, time.January, 6, 0, 0, 0, 0, time.UTC), true},
+		{"timestamp keeps wall-clock time", "2023-01-05T08:30:00+07:00", true, time.Date(2023, time.January, 5, 8, 30, 0, 0, time.UTC), true},
+		{"invalid", "05/01/2023", false, time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/energy/series?from="+url.QueryEscape(test.raw), nil)
+
+			got, ok := queryTime(c, "from", test.endOfDay)
+			if ok != test.ok || !got.Equal(test.want) {
+				t.Errorf("queryTime = %s, %v, want %s, %v", got, ok, test.want, test.ok)
+			}
+			if !ok && recorder.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestGetForecastRejectsLevel(t *testing.T) {
+	for _, raw := range []string{"0", "1", "-0.5", "1.5", "", "high"} {
+		t.Run(raw, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/energy/forecast?level="+url.QueryEscape(raw), nil)
+
+			(&EnergyHandler{}).GetForecast(c)
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
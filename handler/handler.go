@@ -1,8 +1,9 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 
 	"luma-backend/model"
@@ -11,56 +12,37 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-type AIHandler struct {
-	Service *service.AIService
-	Table   map[string][]string
-}
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
 
-func (h *AIHandler) HandleRequest(c *gin.Context) {
-	var input struct {
-		Query     string `json:"query"`
-		SessionID string `json:"session_id"`
-	}
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+const greetingText = "Halo! Saya Luma, AI Assistant yang bisa membantu kamu seputar penggunaan energi di Smarthome kamu. Data yang Anda berikan adalah tentang penggunaan peralatan rumah tangga di berbagai waktu dan kondisi. Apakah Anda ingin saya melakukan analisis atau memberikan informasi lebih lanjut tentang data ini?"
 
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
-		return
-	}
+type AIHandler struct {
+	Service       *service.AIService
+	Datasets      *service.DatasetService
+	Conversations *service.ConversationService
+}
 
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token not found in Authorization header"})
-		return
-	}
+type chatInput struct {
+	Query          string `json:"query"`
+	ConversationID string `json:"conversation_id"`
+	SessionID      string `json:"session_id"`
+}
 
-	sessionID := c.GetHeader("session_id")
-	if sessionID == "" {
-		sessionID = input.SessionID
-	}
-	if sessionID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session ID not found in headers or body"})
+func (h *AIHandler) HandleRequest(c *gin.Context) {
+	input, sessionID, ok := h.beginChat(c)
+	if !ok {
 		return
 	}
 
-	userMessage := model.Message{
-		Role: "user",
-		Parts: []model.Part{
-			{Text: input.Query},
-		},
-	}
-	err := h.Service.SaveChatHistory(sessionID, userMessage)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving chat history"})
-		return
-	}
+	if isGreeting(input.Query) {
+		if _, err := h.Service.SaveChatHistory(sessionID, greetingMessage()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving chat history"})
+			return
+		}
 
-	normalizedQuery := strings.ToLower(strings.TrimSpace(input.Query))
-	if normalizedQuery == "halo" || normalizedQuery == "hi" {
 		response := struct {
 			Answer          string            `json:"answer"`
 			Recommendations []model.Candidate `json:"recommendations"`
@@ -71,7 +53,7 @@ func (h *AIHandler) HandleRequest(c *gin.Context) {
 					Content: model.Content{
 						Role: "assistant",
 						Parts: []model.Part{
-							{Text: "Halo! Saya Luma, AI Assistant yang bisa membantu kamu seputar penggunaan energi di Smarthome kamu. Data yang Anda berikan adalah tentang penggunaan peralatan rumah tangga di berbagai waktu dan kondisi. Apakah Anda ingin saya melakukan analisis atau memberikan informasi lebih lanjut tentang data ini?"},
+							{Text: greetingText},
 						},
 					},
 					FinishReason: "",
@@ -83,57 +65,43 @@ func (h *AIHandler) HandleRequest(c *gin.Context) {
 		return
 	}
 
-	inputs := model.Inputs{
-		Table: h.Table,
-		Query: input.Query,
+	dataset, ok := h.loadDataset(c)
+	if !ok {
+		return
 	}
 
-	huggingfaceToken := os.Getenv("HUGGINGFACE_TOKEN")
-	if huggingfaceToken == "" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "HUGGINGFACE_TOKEN environment variable not set"})
-		return
+	table := dataset.Table
+	inputs := model.Inputs{
+		Table: table,
+		Query: input.Query,
 	}
 
-	response, err := h.Service.GetAIResponse(inputs, huggingfaceToken)
+	response, assistantMessage, err := h.Service.GetAIResponse(inputs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error connecting to AI model"})
 		return
 	}
 
-	assistantMessage := model.Message{
-		Role: "assistant",
-		Parts: []model.Part{
-			{Text: response.Answer},
-		},
-	}
-	err = h.Service.SaveChatHistory(sessionID, assistantMessage)
+	computed := service.Aggregate(service.TypedTable(dataset), response)
+
+	_, err = h.Service.SaveChatHistory(sessionID, assistantMessage)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving chat history"})
 		return
 	}
 
-	apiKey := os.Getenv("API_KEY_GEMINI")
-	if apiKey == "" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "API_KEY_GEMINI environment variable not set"})
-		return
-	}
-
-	geminiResponse, err := h.Service.GetGeminiRecommendation(sessionID, input.Query, h.Table, apiKey)
+	recommendation, recommendationMessages, err := h.Service.GetRecommendation(sessionID, input.Query, dataset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting Gemini recommendation"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting recommendation"})
 		return
 	}
 
-	for i := range geminiResponse.Candidates {
-		geminiResponse.Candidates[i].Content.Role = "assistant"
+	for i := range recommendation.Candidates {
+		recommendation.Candidates[i].Content.Role = "assistant"
 	}
 
-	for _, candidate := range geminiResponse.Candidates {
-		assistantMessage := model.Message{
-			Role:  "assistant",
-			Parts: candidate.Content.Parts,
-		}
-		err := h.Service.SaveChatHistory(sessionID, assistantMessage)
+	for _, recommendationMessage := range recommendationMessages {
+		_, err := h.Service.SaveChatHistory(sessionID, recommendationMessage)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving chat history"})
 			return
@@ -142,27 +110,164 @@ func (h *AIHandler) HandleRequest(c *gin.Context) {
 
 	fullResponse := struct {
 		Answer          string            `json:"answer"`
+		Computed        model.Aggregation `json:"computed"`
 		Recommendations []model.Candidate `json:"recommendations"`
 	}{
 		Answer:          response.Answer,
-		Recommendations: geminiResponse.Candidates,
+		Computed:        computed,
+		Recommendations: recommendation.Candidates,
 	}
 
 	c.JSON(http.StatusOK, fullResponse)
 }
 
 func (h *AIHandler) GetChatHistory(c *gin.Context) {
-	sessionID := c.Query("session_id")
+	sessionID := c.Query("conversation_id")
+	if sessionID == "" {
+		sessionID = c.Query("session_id")
+	}
 	if sessionID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID not provided"})
 		return
 	}
 
-	messages, err := h.Service.GetChatHistory(sessionID)
+	err := h.Conversations.AuthorizeRead(c.GetString("email"), sessionID)
 	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this conversation"})
+		case errors.Is(err, service.ErrConversationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving chat history"})
+		}
+		return
+	}
+
+	limit, ok := queryLimit(c, defaultHistoryLimit, maxHistoryLimit)
+	if !ok {
+		return
+	}
+
+	page, err := h.Service.GetChatHistoryPage(sessionID, c.Query("before"), limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'before' cursor"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving chat history"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"messages": messages})
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *AIHandler) SearchChatHistory(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query 'q' not provided"})
+		return
+	}
+
+	limit, ok := queryLimit(c, defaultHistoryLimit, maxHistoryLimit)
+	if !ok {
+		return
+	}
+
+	results, err := h.Conversations.Search(c.GetString("email"), query, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching chat history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// beginChat validates a chat request and records the user's message. It
+// writes the error response itself and reports whether the caller may go on.
+func (h *AIHandler) beginChat(c *gin.Context) (chatInput, string, bool) {
+	var input chatInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return input, "", false
+	}
+
+	sessionID := input.ConversationID
+	if sessionID == "" {
+		sessionID = c.GetHeader("session_id")
+	}
+	if sessionID == "" {
+		sessionID = input.SessionID
+	}
+	if sessionID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session ID not found in headers or body"})
+		return input, "", false
+	}
+
+	err := h.Conversations.RecordTurn(c.GetString("email"), sessionID, input.Query)
+	if err != nil {
+		if errors.Is(err, service.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this conversation"})
+			return input, "", false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating conversation"})
+		return input, "", false
+	}
+
+	userMessage := model.Message{
+		Role: "user",
+		Parts: []model.Part{
+			{Text: input.Query},
+		},
+		Source: model.SourceUser,
+	}
+	_, err = h.Service.SaveChatHistory(sessionID, userMessage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving chat history"})
+		return input, "", false
+	}
+
+	return input, sessionID, true
+}
+
+func (h *AIHandler) loadDataset(c *gin.Context) (model.Dataset, bool) {
+	dataset, err := h.Datasets.GetDataset(c.GetString("email"), c.GetString("household_id"))
+	if err != nil {
+		if errors.Is(err, service.ErrDatasetNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No dataset uploaded yet. Please upload your household energy data first."})
+			return model.Dataset{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error loading dataset"})
+		return model.Dataset{}, false
+	}
+	return dataset, true
+}
+
+func isGreeting(query string) bool {
+	normalizedQuery := strings.ToLower(strings.TrimSpace(query))
+	return normalizedQuery == "halo" || normalizedQuery == "hi"
+}
+
+func greetingMessage() model.Message {
+	return model.Message{
+		Role: "assistant",
+		Parts: []model.Part{
+			{Text: greetingText},
+		},
+		Source: model.SourceGreeting,
+	}
+}
+
+func queryLimit(c *gin.Context, fallback, max int) (int, bool) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return fallback, true
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > max {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'limit' must be between 1 and " + strconv.Itoa(max)})
+		return 0, false
+	}
+	return limit, true
 }
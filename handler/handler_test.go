@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"luma-backend/model"
+	"luma-backend/repository"
+	"luma-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+type noSessions struct{}
+
+func (noSessions) FindSessionOwner(string) (string, error) { return "", nil }
+
+type soloHouseholds struct{}
+
+func (soloHouseholds) MemberEmails(email string) ([]string, error) { return []string{email}, nil }
+
+// newChatRouter serves the chat and conversation routes for whoever the
+// X-Test-Email header names, standing in for AuthMiddleware.
+func newChatRouter(chats repository.ChatStore) *gin.Engine {
+	conversations := &service.ConversationService{ChatRepo: chats, MongoRepo: noSessions{}, Households: soloHouseholds{}}
+	ai := &AIHandler{
+		Service:       &service.AIService{TableQA: &repository.FakeProvider{}, Recommender: &repository.FakeProvider{}, ChatRepo: chats},
+		Conversations: conversations,
+	}
+	conversationHandler := &ConversationHandler{Service: conversations}
+
+	router := gin.New()
+	api := router.Group("/api", func(c *gin.Context) {
+		c.Set("email", c.GetHeader("X-Test-Email"))
+	})
+	api.POST("/chat", ai.HandleRequest)
+	api.POST("/chat/stream", ai.HandleStream)
+	api.GET("/chat-history", ai.GetChatHistory)
+	api.PATCH("/conversations/:id", conversationHandler.RenameConversation)
+	api.DELETE("/conversations/:id", conversationHandler.DeleteConversation)
+	return router
+}
+
+func TestChatRoutesRejectOtherUsers(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"read history", http.MethodGet, "/api/chat-history?conversation_id=alice-conversation", ""},
+		{"post chat", http.MethodPost, "/api/chat", `{"query":"halo","conversation_id":"alice-conversation"}`},
+		{"post chat stream", http.MethodPost, "/api/chat/stream", `{"query":"halo","conversation_id":"alice-conversation"}`},
+		{"rename", http.MethodPatch, "/api/conversations/alice-conversation", `{"title":"Milik Bob"}`},
+		{"delete", http.MethodDelete, "/api/conversations/alice-conversation", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chats := repository.NewMemoryChatRepository()
+			now := time.Now()
+			chats.CreateConversation(model.Conversation{ID: "alice-conversation", Email: "alice@example.com", Title: "Tagihan", CreatedAt: now, UpdatedAt: now})
+			chats.SaveMessage("alice-conversation", model.Message{Role: "user", Parts: []model.Part{{Text: "Berapa tagihan saya?"}}})
+			router := newChatRouter(chats)
+
+			request := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			request.Header.Set("Content-Type", "application/json")
+			request.Header.Set("X-Test-Email", "bob@example.com")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusForbidden, recorder.Body)
+			}
+			messages, _ := chats.GetMessages("alice-conversation")
+			if len(messages) != 1 {
+				t.Errorf("alice's conversation has %d messages after bob's request, want 1", len(messages))
+			}
+			conversation, _ := chats.GetConversation("alice-conversation")
+			if conversation == nil || conversation.Title != "Tagihan" {
+				t.Errorf("alice's conversation changed after bob's request: %+v", conversation)
+			}
+		})
+	}
+}
+
+func TestStreamDoneSendsStoredMessage(t *testing.T) {
+	chats := repository.NewMemoryChatRepository()
+	router := newChatRouter(chats)
+
+	request := httptest.NewRequest(http.MethodPost, "/api/chat/stream", strings.NewReader(`{"query":"halo","conversation_id":"new-conversation"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Test-Email", "alice@example.com")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	var done struct {
+		Message model.Message `json:"message"`
+	}
+	events := strings.Split(recorder.Body.String(), "\n\n")
+	for _, event := range events {
+		if strings.HasPrefix(event, "event:done\n") {
+			data := strings.TrimPrefix(strings.SplitN(event, "\n", 2)[1], "data:")
+			if err := json.Unmarshal([]byte(data), &done); err != nil {
+				t.Fatalf("decode done event: %v", err)
+			}
+		}
+	}
+
+	messages, _ := chats.GetMessages("new-conversation")
+	if len(messages) != 2 {
+		t.Fatalf("stored %d messages, want the question and the greeting", len(messages))
+	}
+	stored := messages[1]
+	if done.Message.ID == "" || done.Message.ID != stored.ID || !done.Message.CreatedAt.Equal(stored.CreatedAt) {
+		t.Errorf("done message = %+v, want the stored greeting %+v", done.Message, stored)
+	}
+}
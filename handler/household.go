@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"luma-backend/model"
+	"luma-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HouseholdHandler struct {
+	Service *service.HouseholdService
+}
+
+func (h *HouseholdHandler) GetHousehold(c *gin.Context) {
+	invitations, err := h.Service.Invitations(c.GetString("email"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving invitations"})
+		return
+	}
+
+	response := gin.H{"household": nil, "invitations": invitations}
+	if household, ok := currentHousehold(c); ok {
+		response["household"] = household
+		response["role"] = c.GetString("household_role")
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *HouseholdHandler) CreateHousehold(c *gin.Context) {
+	var input struct {
+		Name string `json:"name"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	household, err := h.Service.Create(c.GetString("email"), input.Name)
+	if err != nil {
+		if errors.Is(err, service.ErrAlreadyInHousehold) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You already belong to a household"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating household"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, household)
+}
+
+func (h *HouseholdHandler) InviteMember(c *gin.Context) {
+	household, ok := requireHousehold(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Email string              `json:"email" binding:"required,email"`
+		Role  model.HouseholdRole `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, err := h.Service.Invite(household, c.GetString("email"), input.Email, input.Role)
+	if err != nil {
+		householdError(c, err, "Error inviting member")
+		return
+	}
+
+	c.JSON(http.StatusCreated, member)
+}
+
+func (h *HouseholdHandler) UpdateMember(c *gin.Context) {
+	household, ok := requireHousehold(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Role model.HouseholdRole `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.Service.UpdateRole(household, c.Param("email"), input.Role)
+	if err != nil {
+		householdError(c, err, "Error updating member")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member role updated"})
+}
+
+// RemoveMember lets owners remove anyone and lets every member remove
+// themselves to leave the household.
+func (h *HouseholdHandler) RemoveMember(c *gin.Context) {
+	household, ok := requireHousehold(c)
+	if !ok {
+		return
+	}
+
+	email := c.Param("email")
+	role := model.HouseholdRole(c.GetString("household_role"))
+	if email != c.GetString("email") && !role.Can(model.PermissionManageMembers) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only household owners can remove other members"})
+		return
+	}
+
+	err := h.Service.RemoveMember(household, email)
+	if err != nil {
+		householdError(c, err, "Error removing member")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+func (h *HouseholdHandler) AcceptInvitation(c *gin.Context) {
+	err := h.Service.AcceptInvitation(c.GetString("email"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrAlreadyInHousehold) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Leave your current household before joining another"})
+			return
+		}
+		householdError(c, err, "Error accepting invitation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted"})
+}
+
+func (h *HouseholdHandler) DeclineInvitation(c *gin.Context) {
+	err := h.Service.DeclineInvitation(c.GetString("email"), c.Param("id"))
+	if err != nil {
+		householdError(c, err, "Error declining invitation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation declined"})
+}
+
+func currentHousehold(c *gin.Context) (model.Household, bool) {
+	value, ok := c.Get("household")
+	if !ok {
+		return model.Household{}, false
+	}
+	household, ok := value.(model.Household)
+	return household, ok
+}
+
+func requireHousehold(c *gin.Context) (model.Household, bool) {
+	household, ok := currentHousehold(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not a member of a household"})
+	}
+	return household, ok
+}
+
+func householdError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrHouseholdNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Household invitation not found"})
+	case errors.Is(err, service.ErrMemberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+	case errors.Is(err, service.ErrAlreadyMember):
+		c.JSON(http.StatusConflict, gin.H{"error": "This user is already a member or has a pending invitation"})
+	case errors.Is(err, service.ErrInvalidRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be one of owner, member or viewer"})
+	case errors.Is(err, service.ErrLastOwner):
+		c.JSON(http.StatusConflict, gin.H{"error": "A household needs at least one owner"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
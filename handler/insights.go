@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"luma-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultInsightsLimit = 50
+	maxInsightsLimit     = 500
+)
+
+type InsightsHandler struct {
+	Energy *service.EnergyService
+	Costs  *service.CostService
+}
+
+func (h *InsightsHandler) GetAnomalies(c *gin.Context) {
+	from, ok := queryTime(c, "from", false)
+	if !ok {
+		return
+	}
+	to, ok := queryTime(c, "to", true)
+	if !ok {
+		return
+	}
+	limit, ok := queryLimit(c, defaultInsightsLimit, maxInsightsLimit)
+	if !ok {
+		return
+	}
+
+	threshold := service.DefaultAnomalyThreshold
+	if raw := c.Query("threshold"); raw != "" {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || value <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'threshold' must be a positive number"})
+			return
+		}
+		threshold = value
+	}
+
+	anomalies, err := h.Energy.Anomalies(c.GetString("email"), c.GetString("household_id"), service.AnomalyQuery{
+		Appliance: c.Query("appliance"),
+		From:      from,
+		To:        to,
+		Threshold: threshold,
+		Limit:     limit,
+	})
+	if err != nil {
+		energyError(c, err, "Error detecting anomalies")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"threshold": threshold, "anomalies": anomalies})
+}
+
+func (h *InsightsHandler) GetStandby(c *gin.Context) {
+	from, ok := queryTime(c, "from", false)
+	if !ok {
+		return
+	}
+	to, ok := queryTime(c, "to", true)
+	if !ok {
+		return
+	}
+
+	report, err := h.Costs.Standby(c.GetString("email"), c.GetString("household_id"), from, to)
+	if err != nil {
+		energyError(c, err, "Error detecting standby consumption")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -2,65 +2,114 @@ package handler
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"luma-backend/model"
 	"luma-backend/repository"
+	"luma-backend/service"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-	googleoauth "google.golang.org/api/oauth2/v2"
-	"google.golang.org/api/option"
 )
 
-var googleOauthConfig = &oauth2.Config{
-	RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URL"),
-	ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-	ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-	Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/userinfo.profile"},
-	Endpoint:     google.Endpoint,
-}
-
 type OAuthHandler struct {
 	MongoRepo *repository.MongoRepository
+	Auth      *service.AuthService
+	Providers map[string]repository.IdentityProvider
+	// StateKey signs the OAuth state cookie; see service.LoadOAuthStateKey.
+	StateKey []byte
 }
 
-func (h *OAuthHandler) GoogleLogin(c *gin.Context) {
-	url := googleOauthConfig.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+func (h *OAuthHandler) ListProviders(c *gin.Context) {
+	names := make([]string, 0, len(h.Providers))
+	for name := range h.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.JSON(http.StatusOK, gin.H{"providers": names})
+}
+
+func (h *OAuthHandler) Login(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
+
+	state, err := newOAuthState(provider.Name())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login: " + err.Error()})
+		return
+	}
+
+	err = setOAuthStateCookie(c, h.StateKey, state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login: " + err.Error()})
+		return
+	}
+
+	url := provider.OAuthConfig().AuthCodeURL(state.State, state.authCodeOptions()...)
 	c.Redirect(http.StatusFound, url)
 }
 
-func (h *OAuthHandler) GoogleCallback(c *gin.Context) {
-	code := c.Query("code")
+func (h *OAuthHandler) Callback(c *gin.Context) {
+	provider, ok := h.provider(c)
+	if !ok {
+		return
+	}
 
-	token, err := googleOauthConfig.Exchange(context.Background(), code)
+	state, err := verifyOAuthState(c, h.StateKey, provider.Name())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to exchange token: " + err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	client := googleOauthConfig.Client(context.Background(), token)
-	oauth2Service, err := googleoauth.NewService(context.Background(), option.WithHTTPClient(client))
+	if errorCode := c.Query("error"); errorCode != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login was not completed: " + errorCode})
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code not provided"})
+		return
+	}
+
+	token, err := provider.OAuthConfig().Exchange(context.Background(), code, oauth2.VerifierOption(state.Verifier))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create OAuth2 service: " + err.Error()})
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code or PKCE verifier was rejected: " + retrieveErr.ErrorCode})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to exchange token: " + err.Error()})
 		return
 	}
 
-	userinfo, err := oauth2Service.Userinfo.Get().Do()
+	profile, err := provider.Profile(context.Background(), token)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user info: " + err.Error()})
 		return
 	}
 
+	// Accounts are matched across providers by email, so an address the
+	// provider has not verified could be used to take over someone else's.
+	if profile.Email == "" || !profile.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your " + provider.Name() + " account has no verified email address"})
+		return
+	}
+
 	user := model.User{
-		Email:   userinfo.Email,
-		Name:    userinfo.Name,
-		Picture: userinfo.Picture,
+		Email:   profile.Email,
+		Name:    profile.Name,
+		Picture: profile.Picture,
 	}
 
 	existingUser, err := h.MongoRepo.FindUserByEmail(user.Email)
@@ -77,54 +126,51 @@ func (h *OAuthHandler) GoogleCallback(c *gin.Context) {
 		}
 	}
 
-	sessionID := uuid.New().String()
+	err = h.MongoRepo.LinkIdentity(user.Email, model.Identity{
+		Provider: profile.Provider,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+		LinkedAt: time.Now(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link identity: " + err.Error()})
+		return
+	}
 
-	err = h.MongoRepo.SaveSession(sessionID, user)
+	session, err := h.Auth.CreateSession(user, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save session: " + err.Error()})
 		return
 	}
+	sessionID := session.ID
 
-	jwtToken, err := generateJWT(user)
+	jwtToken, err := h.Auth.IssueToken(user, sessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate JWT: " + err.Error()})
 		return
 	}
 
-	http.SetCookie(c.Writer, &http.Cookie{
-		Name:     "jwt_token",
-		Value:    jwtToken,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(72 * time.Hour),
-	})
+	refreshToken, err := h.Auth.IssueRefreshToken(session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue refresh token: " + err.Error()})
+		return
+	}
 
-	http.SetCookie(c.Writer, &http.Cookie{
-		Name:     "session_id",
-		Value:    sessionID,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(72 * time.Hour),
-	})
+	err = setAuthCookies(c, jwtToken, refreshToken, session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set session cookies: " + err.Error()})
+		return
+	}
 
 	c.Redirect(http.StatusFound, os.Getenv("FRONTEND_CHAT"))
 }
 
-func generateJWT(user model.User) (string, error) {
-	secretKey := os.Getenv("JWT_SECRET")
-	claims := jwt.MapClaims{
-		"email":   user.Email,
-		"name":    user.Name,
-		"picture": user.Picture,
-		"exp":     time.Now().Add(time.Hour * 72).Unix(),
+func (h *OAuthHandler) provider(c *gin.Context) (repository.IdentityProvider, bool) {
+	provider, ok := h.Providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown login provider: " + c.Param("provider")})
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secretKey))
+	return provider, ok
 }
 
 func (h *OAuthHandler) UserInfo(c *gin.Context) {
@@ -134,19 +180,15 @@ func (h *OAuthHandler) UserInfo(c *gin.Context) {
 		return
 	}
 
-	claims := &jwt.MapClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
-
-	if err != nil || !token.Valid {
+	claims, err := h.Auth.Authenticate(tokenString)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	email := (*claims)["email"].(string)
-	name := (*claims)["name"].(string)
-	picture := (*claims)["picture"].(string)
+	email, _ := claims["email"].(string)
+	name, _ := claims["name"].(string)
+	picture, _ := claims["picture"].(string)
 
 	c.JSON(http.StatusOK, gin.H{
 		"email":   email,
@@ -156,25 +198,184 @@ func (h *OAuthHandler) UserInfo(c *gin.Context) {
 }
 
 func (h *OAuthHandler) Logout(c *gin.Context) {
+	err := h.revokeCurrentSession(c)
+	if err != nil && !errors.Is(err, service.ErrSessionNotFound) && !errors.Is(err, service.ErrInvalidRefreshToken) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session: " + err.Error()})
+		return
+	}
+
+	clearAuthCookies(c)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully logged out"})
+	c.Redirect(http.StatusFound, os.Getenv("FRONTEND_URL"))
+}
+
+// revokeCurrentSession revokes the session behind the caller's access token,
+// falling back to the refresh token cookie once the access token has expired.
+func (h *OAuthHandler) revokeCurrentSession(c *gin.Context) error {
+	tokenString, err := c.Cookie("jwt_token")
+	if err != nil || tokenString == "" {
+		tokenString = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+
+	if claims, err := h.Auth.ParseToken(tokenString); err == nil {
+		email, _ := claims["email"].(string)
+		sessionID, _ := claims["sid"].(string)
+		return h.Auth.RevokeSession(email, sessionID)
+	}
+
+	if refreshToken, err := c.Cookie("refresh_token"); err == nil && refreshToken != "" {
+		return h.Auth.RevokeRefreshToken(refreshToken)
+	}
+	return nil
+}
+
+func (h *OAuthHandler) ListSessions(c *gin.Context) {
+	sessions, err := h.Auth.ListSessions(c.GetString("email"), c.GetString("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+func (h *OAuthHandler) RevokeSession(c *gin.Context) {
+	err := h.Auth.RevokeSession(c.GetString("email"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+func (h *OAuthHandler) Refresh(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	fromBody := input.RefreshToken != ""
+	refreshToken := input.RefreshToken
+	if !fromBody {
+		refreshToken, _ = c.Cookie("refresh_token")
+	}
+	if refreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token not provided"})
+		return
+	}
+
+	accessToken, newRefreshToken, session, err := h.Auth.Refresh(refreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRefreshTokenReused):
+			clearAuthCookies(c)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token was already used. All sessions for this login have been revoked; please log in again."})
+		case errors.Is(err, service.ErrInvalidRefreshToken), errors.Is(err, service.ErrSessionRevoked):
+			clearAuthCookies(c)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token. Please log in again."})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token: " + err.Error()})
+		}
+		return
+	}
+
+	err = setAuthCookies(c, accessToken, newRefreshToken, session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set session cookies: " + err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(service.AccessTokenTTL.Seconds()),
+		"session_id":   session.ID,
+	}
+	if fromBody {
+		response["refresh_token"] = newRefreshToken
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func setAuthCookies(c *gin.Context, accessToken, refreshToken string, session model.Session) error {
+	csrf := make([]byte, 32)
+	if _, err := rand.Read(csrf); err != nil {
+		return err
+	}
+
 	http.SetCookie(c.Writer, &http.Cookie{
 		Name:     "jwt_token",
-		Value:    "",
+		Value:    accessToken,
 		Path:     "/",
 		HttpOnly: true,
 		Secure:   true,
 		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(-1 * time.Hour),
+		Expires:  time.Now().Add(service.AccessTokenTTL),
 	})
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		Path:     "/auth",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Expires:  session.ExpiresAt,
+	})
+
 	http.SetCookie(c.Writer, &http.Cookie{
 		Name:     "session_id",
-		Value:    "",
+		Value:    session.ID,
 		Path:     "/",
 		HttpOnly: true,
 		Secure:   true,
 		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(-1 * time.Hour),
+		Expires:  session.ExpiresAt,
 	})
 
-	c.JSON(http.StatusOK, gin.H{"message": "Successfully logged out"})
-	c.Redirect(http.StatusFound, os.Getenv("FRONTEND_URL"))
+	// Readable by the frontend so it can echo it in the X-CSRF-Token header.
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     "csrf_token",
+		Value:    base64.RawURLEncoding.EncodeToString(csrf),
+		Path:     "/",
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Expires:  session.ExpiresAt,
+	})
+	return nil
+}
+
+func clearAuthCookies(c *gin.Context) {
+	for _, cookie := range []struct{ name, path string }{
+		{"jwt_token", "/"},
+		{"refresh_token", "/auth"},
+		{"session_id", "/"},
+		{"csrf_token", "/"},
+	} {
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     cookie.name,
+			Value:    "",
+			Path:     cookie.path,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+			Expires:  time.Now().Add(-1 * time.Hour),
+		})
+	}
+}
+
+func (h *OAuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.Auth.Keys.JWKS())
 }
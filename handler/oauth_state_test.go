@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stateCallback signs state with signKey as Login would, then replays the
+// cookie on a callback verified with verifyKey.
+func stateCallback(t *testing.T, signKey, verifyKey []byte, query string) (oauthState, error) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	state, err := newOAuthState("google")
+	if err != nil {
+		t.Fatalf("newOAuthState: %v", err)
+	}
+	login := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(login)
+	if err := setOAuthStateCookie(c, signKey, state); err != nil {
+		t.Fatalf("setOAuthStateCookie: %v", err)
+	}
+
+	if query == "" {
+		query = state.State
+	}
+	req := httptest.NewRequest(http.MethodGet, "/auth/google/callback?state="+query, nil)
+	for _, cookie := range login.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	verified, err := verifyOAuthState(c, verifyKey, "google")
+	if err == nil && verified.Verifier != state.Verifier {
+		t.Errorf("verifier = %q, want %q", verified.Verifier, state.Verifier)
+	}
+	return verified, err
+}
+
+func TestVerifyOAuthState(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	other := []byte("fedcba9876543210fedcba9876543210")
+
+	tests := []struct {
+		name      string
+		verifyKey []byte
+		query     string
+		want      error
+	}{
+		{"same key", key, "", nil},
+		{"signed with a different key", other, "", errInvalidOAuthState},
+		{"signed with an empty key", nil, "", errInvalidOAuthState},
+		{"state parameter from another login", key, "forged", errOAuthStateMismatch},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := stateCallback(t, key, test.verifyKey, test.query); !errors.Is(err, test.want) {
+				t.Errorf("err = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net/http"
+
+	"luma-backend/model"
+	"luma-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleStream answers like HandleRequest but relays the result as
+// Server-Sent Events: an "answer" event once TAPAS responds, "token" events
+// while the recommendation is generated, then "done" or "error".
+func (h *AIHandler) HandleStream(c *gin.Context) {
+	input, sessionID, ok := h.beginChat(c)
+	if !ok {
+		return
+	}
+
+	if isGreeting(input.Query) {
+		greeting, err := h.Service.SaveChatHistory(sessionID, greetingMessage())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving chat history"})
+			return
+		}
+
+		startStream(c)
+		sendEvent(c, "answer", gin.H{"answer": ""})
+		sendEvent(c, "token", gin.H{"text": greetingText})
+		sendEvent(c, "done", gin.H{"message": greeting})
+		return
+	}
+
+	dataset, ok := h.loadDataset(c)
+	if !ok {
+		return
+	}
+
+	response, assistantMessage, err := h.Service.GetAIResponse(model.Inputs{Table: dataset.Table, Query: input.Query})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error connecting to AI model"})
+		return
+	}
+
+	_, err = h.Service.SaveChatHistory(sessionID, assistantMessage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving chat history"})
+		return
+	}
+
+	startStream(c)
+	sendEvent(c, "answer", gin.H{
+		"answer":   response.Answer,
+		"computed": service.Aggregate(service.TypedTable(dataset), response),
+	})
+
+	recommendationMessage, err := h.Service.StreamRecommendation(c.Request.Context(), sessionID, input.Query, dataset, func(chunk string) error {
+		if err := c.Request.Context().Err(); err != nil {
+			return err
+		}
+		sendEvent(c, "token", gin.H{"text": chunk})
+		return nil
+	})
+
+	if len(recommendationMessage.Parts) > 0 && recommendationMessage.Parts[0].Text != "" {
+		saved, saveErr := h.Service.SaveChatHistory(sessionID, recommendationMessage)
+		if saveErr != nil && err == nil {
+			err = saveErr
+		}
+		if saveErr == nil {
+			recommendationMessage = saved
+		}
+	}
+
+	if err != nil {
+		sendEvent(c, "error", gin.H{"error": "Error getting recommendation"})
+		return
+	}
+
+	sendEvent(c, "done", gin.H{"message": recommendationMessage})
+}
+
+func startStream(c *gin.Context) {
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+}
+
+func sendEvent(c *gin.Context, event string, data interface{}) {
+	c.SSEvent(event, data)
+	c.Writer.Flush()
+}
@@ -1,16 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 
 	"luma-backend/handler"
 	"luma-backend/middleware"
+	"luma-backend/model"
 	"luma-backend/repository"
 	"luma-backend/service"
 )
@@ -22,56 +25,212 @@ func main() {
 		return
 	}
 
-	file, err := os.Open("data-series.csv")
+	mongoRepo, err := repository.NewMongoRepository(os.Getenv("MONGODB_URI"), os.Getenv("MONGO_DB"))
 	if err != nil {
-		fmt.Println("Error opening file:", err)
+		fmt.Println("Error connecting to MongoDB:", err)
 		return
 	}
-	defer file.Close()
 
-	data, err := io.ReadAll(file)
+	err = mongoRepo.EnsureTokenIndexes()
 	if err != nil {
-		fmt.Println("Error reading file:", err)
+		fmt.Println("Error creating token indexes:", err)
 		return
 	}
 
-	table, err := repository.CsvToSlice(string(data))
+	chatRepo := repository.NewChatRepository(mongoRepo.Client, os.Getenv("MONGO_DB"))
+	err = chatRepo.EnsureIndexes()
+	if err != nil {
+		fmt.Println("Error creating chat indexes:", err)
+		return
+	}
+	datasetRepo := repository.NewDatasetRepository(mongoRepo.Client, os.Getenv("MONGO_DB"))
+	householdRepo := repository.NewHouseholdRepository(mongoRepo.Client, os.Getenv("MONGO_DB"))
+	err = householdRepo.EnsureIndexes()
 	if err != nil {
-		fmt.Println("Error parsing CSV:", err)
+		fmt.Println("Error creating household indexes:", err)
 		return
 	}
 
-	mongoRepo, err := repository.NewMongoRepository(os.Getenv("MONGODB_URI"), os.Getenv("MONGO_DB"))
+	tableQA, recommender, err := newAIProviders(&http.Client{})
 	if err != nil {
-		fmt.Println("Error connecting to MongoDB:", err)
+		fmt.Println("Error configuring AI providers:", err)
 		return
 	}
 
-	chatRepo := repository.NewChatRepository(mongoRepo.Client, os.Getenv("MONGO_DB"))
+	budget := service.DefaultPromptBudget
+	if value, err := strconv.Atoi(os.Getenv("CONTEXT_TOKEN_BUDGET")); err == nil && value > 0 {
+		budget.MaxTokens = value
+		budget.MaxTableTokens = value * 3 / 8
+	}
 
-	aiModelConnector := &repository.AIModelConnector{Client: &http.Client{}}
-	aiService := &service.AIService{Connector: aiModelConnector, ChatRepo: chatRepo}
-	aiHandler := &handler.AIHandler{Service: aiService, Table: table}
-	oauthHandler := &handler.OAuthHandler{MongoRepo: mongoRepo}
+	datasetService := &service.DatasetService{DatasetRepo: datasetRepo}
+	energyService := &service.EnergyService{Datasets: datasetService}
+	costService := &service.CostService{DatasetRepo: datasetRepo, Energy: energyService}
+	aiService := &service.AIService{TableQA: tableQA, Recommender: recommender, ChatRepo: chatRepo, Costs: costService, Budget: budget}
+	householdService := &service.HouseholdService{HouseholdRepo: householdRepo}
+	conversationService := &service.ConversationService{ChatRepo: chatRepo, MongoRepo: mongoRepo, Households: householdService}
+	aiHandler := &handler.AIHandler{Service: aiService, Datasets: datasetService, Conversations: conversationService}
+	conversationHandler := &handler.ConversationHandler{Service: conversationService}
+	datasetHandler := &handler.DatasetHandler{Service: datasetService}
+	householdHandler := &handler.HouseholdHandler{Service: householdService}
+	energyHandler := &handler.EnergyHandler{Service: energyService, Costs: costService}
+	insightsHandler := &handler.InsightsHandler{Energy: energyService, Costs: costService}
+	keys, err := service.LoadKeySet()
+	if err != nil {
+		fmt.Println("Error loading JWT signing keys:", err)
+		return
+	}
+
+	stateKey, err := service.LoadOAuthStateKey()
+	if err != nil {
+		fmt.Println("Error loading OAuth state key:", err)
+		return
+	}
+
+	identityProviders, err := newIdentityProviders(&http.Client{})
+	if err != nil {
+		fmt.Println("Error configuring login providers:", err)
+		return
+	}
+
+	authService := &service.AuthService{MongoRepo: mongoRepo, Keys: keys}
+	oauthHandler := &handler.OAuthHandler{MongoRepo: mongoRepo, Auth: authService, Providers: identityProviders, StateKey: stateKey}
+	apiKeyHandler := &handler.APIKeyHandler{Auth: authService}
 
 	router := gin.Default()
 
 	router.Use(middleware.CORSMiddleware())
 
+	router.GET("/.well-known/jwks.json", oauthHandler.JWKS)
+
 	auth := router.Group("/auth")
 	{
-		auth.GET("/google/login", middleware.CheckLoginMiddleware(), oauthHandler.GoogleLogin)
-		auth.GET("/google/callback", oauthHandler.GoogleCallback)
-		auth.GET("/logout", oauthHandler.Logout)
+		auth.GET("/providers", oauthHandler.ListProviders)
+		auth.GET("/:provider/login", middleware.CheckLoginMiddleware(authService), oauthHandler.Login)
+		auth.GET("/:provider/callback", oauthHandler.Callback)
+		auth.POST("/logout", middleware.RequireCSRF(), oauthHandler.Logout)
+		auth.POST("/refresh", oauthHandler.Refresh)
 		auth.GET("/userinfo", oauthHandler.UserInfo)
+		auth.GET("/sessions", middleware.AuthMiddleware(authService), middleware.RequireSession(), oauthHandler.ListSessions)
+		auth.DELETE("/sessions/:id", middleware.AuthMiddleware(authService), middleware.RequireSession(), oauthHandler.RevokeSession)
 	}
 
 	api := router.Group("/api")
 	{
-		api.Use(middleware.AuthMiddleware())
-		api.POST("/chat", aiHandler.HandleRequest)
-		api.GET("/chat-history", aiHandler.GetChatHistory)
+		api.Use(middleware.AuthMiddleware(authService), middleware.HouseholdMiddleware(householdService))
+		api.POST("/chat", middleware.RequireScope(model.ScopeChatWrite), middleware.RequirePermission(model.PermissionChat), aiHandler.HandleRequest)
+		api.POST("/chat/stream", middleware.RequireScope(model.ScopeChatWrite), middleware.RequirePermission(model.PermissionChat), aiHandler.HandleStream)
+		api.GET("/chat-history", middleware.RequireScope(model.ScopeChatRead), middleware.RequirePermission(model.PermissionViewHistory), aiHandler.GetChatHistory)
+		api.GET("/chat-history/search", middleware.RequireScope(model.ScopeChatRead), middleware.RequirePermission(model.PermissionViewHistory), aiHandler.SearchChatHistory)
+		api.GET("/conversations", middleware.RequireScope(model.ScopeChatRead), middleware.RequirePermission(model.PermissionViewHistory), conversationHandler.ListConversations)
+		api.POST("/conversations", middleware.RequireScope(model.ScopeChatWrite), middleware.RequirePermission(model.PermissionChat), conversationHandler.CreateConversation)
+		api.PATCH("/conversations/:id", middleware.RequireScope(model.ScopeChatWrite), middleware.RequirePermission(model.PermissionChat), conversationHandler.RenameConversation)
+		api.DELETE("/conversations/:id", middleware.RequireScope(model.ScopeChatWrite), middleware.RequirePermission(model.PermissionChat), conversationHandler.DeleteConversation)
+		api.POST("/datasets", middleware.RequireScope(model.ScopeDatasetsWrite), middleware.RequirePermission(model.PermissionUploadDataset), datasetHandler.UploadDataset)
+		api.GET("/datasets", middleware.RequireScope(model.ScopeDatasetsRead), datasetHandler.GetDataset)
+		api.GET("/energy/series", middleware.RequireScope(model.ScopeDatasetsRead), energyHandler.GetSeries)
+		api.GET("/energy/forecast", middleware.RequireScope(model.ScopeDatasetsRead), energyHandler.GetForecast)
+		api.GET("/energy/cost", middleware.RequireScope(model.ScopeDatasetsRead), energyHandler.GetCost)
+		api.GET("/insights/anomalies", middleware.RequireScope(model.ScopeDatasetsRead), insightsHandler.GetAnomalies)
+		api.GET("/insights/standby", middleware.RequireScope(model.ScopeDatasetsRead), insightsHandler.GetStandby)
+		api.GET("/tariff", middleware.RequireScope(model.ScopeDatasetsRead), energyHandler.GetTariff)
+		api.PUT("/tariff", middleware.RequireScope(model.ScopeDatasetsWrite), middleware.RequirePermission(model.PermissionManageTariff), energyHandler.UpdateTariff)
+		api.GET("/keys", middleware.RequireSession(), apiKeyHandler.ListAPIKeys)
+		api.POST("/keys", middleware.RequireSession(), apiKeyHandler.CreateAPIKey)
+		api.DELETE("/keys/:id", middleware.RequireSession(), apiKeyHandler.RevokeAPIKey)
+		api.GET("/household", householdHandler.GetHousehold)
+		api.POST("/household", middleware.RequireSession(), householdHandler.CreateHousehold)
+		api.POST("/household/members", middleware.RequireSession(), middleware.RequirePermission(model.PermissionManageMembers), householdHandler.InviteMember)
+		api.PATCH("/household/members/:email", middleware.RequireSession(), middleware.RequirePermission(model.PermissionManageMembers), householdHandler.UpdateMember)
+		api.DELETE("/household/members/:email", middleware.RequireSession(), householdHandler.RemoveMember)
+		api.POST("/household/invitations/:id/accept", middleware.RequireSession(), householdHandler.AcceptInvitation)
+		api.DELETE("/household/invitations/:id", middleware.RequireSession(), householdHandler.DeclineInvitation)
 	}
 
 	router.Run(":8080")
 }
+
+func newAIProviders(client *http.Client) (repository.TableQAProvider, repository.RecommendationProvider, error) {
+	var tableQA repository.TableQAProvider
+	switch provider := envOrDefault("TABLE_QA_PROVIDER", "huggingface"); provider {
+	case "huggingface":
+		token := os.Getenv("HUGGINGFACE_TOKEN")
+		if token == "" {
+			return nil, nil, fmt.Errorf("HUGGINGFACE_TOKEN environment variable not set")
+		}
+		tableQA = &repository.HuggingFaceProvider{
+			Client:  client,
+			BaseURL: envOrDefault("HUGGINGFACE_BASE_URL", repository.DefaultHuggingFaceBaseURL),
+			Model:   envOrDefault("TABLE_QA_MODEL", repository.DefaultTableQAModel),
+			Token:   token,
+		}
+	case "fake":
+		tableQA = &repository.FakeProvider{}
+	default:
+		return nil, nil, fmt.Errorf("unknown TABLE_QA_PROVIDER %q", provider)
+	}
+
+	var recommender repository.RecommendationProvider
+	switch provider := envOrDefault("RECOMMENDATION_PROVIDER", "gemini"); provider {
+	case "gemini":
+		apiKey := os.Getenv("API_KEY_GEMINI")
+		if apiKey == "" {
+			return nil, nil, fmt.Errorf("API_KEY_GEMINI environment variable not set")
+		}
+		recommender = &repository.GeminiProvider{
+			Client:  client,
+			BaseURL: envOrDefault("GEMINI_BASE_URL", repository.DefaultGeminiBaseURL),
+			Model:   envOrDefault("GEMINI_MODEL", repository.DefaultGeminiModel),
+			APIKey:  apiKey,
+		}
+	case "fake":
+		recommender = &repository.FakeProvider{}
+	default:
+		return nil, nil, fmt.Errorf("unknown RECOMMENDATION_PROVIDER %q", provider)
+	}
+
+	return tableQA, recommender, nil
+}
+
+// newIdentityProviders registers every login provider whose client ID is
+// configured. Google stays enabled unconditionally as the default login.
+func newIdentityProviders(client *http.Client) (map[string]repository.IdentityProvider, error) {
+	providers := map[string]repository.IdentityProvider{}
+
+	google := repository.NewGoogleIdentityProvider(os.Getenv("GOOGLE_CLIENT_ID"), os.Getenv("GOOGLE_CLIENT_SECRET"), os.Getenv("GOOGLE_REDIRECT_URL"))
+	providers[google.Name()] = google
+
+	if clientID := os.Getenv("GITHUB_CLIENT_ID"); clientID != "" {
+		github := repository.NewGitHubIdentityProvider(clientID, os.Getenv("GITHUB_CLIENT_SECRET"), os.Getenv("GITHUB_REDIRECT_URL"))
+		providers[github.Name()] = github
+	}
+
+	if issuer := os.Getenv("OIDC_ISSUER_URL"); issuer != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		oidc, err := repository.NewOIDCIdentityProvider(ctx, client,
+			envOrDefault("OIDC_PROVIDER_NAME", "oidc"),
+			issuer,
+			os.Getenv("OIDC_CLIENT_ID"),
+			os.Getenv("OIDC_CLIENT_SECRET"),
+			os.Getenv("OIDC_REDIRECT_URL"),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := providers[oidc.Name()]; exists {
+			return nil, fmt.Errorf("OIDC_PROVIDER_NAME %q clashes with a built-in provider", oidc.Name())
+		}
+		providers[oidc.Name()] = oidc
+	}
+
+	return providers, nil
+}
+
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"luma-backend/model"
+	"luma-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyStore keeps API keys in memory. The embedded AuthStore is nil, so any
+// session or refresh-token call fails the test with a panic.
+type apiKeyStore struct {
+	service.AuthStore
+	keys []model.APIKey
+}
+
+func (s *apiKeyStore) SaveAPIKey(key model.APIKey) error {
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+func (s *apiKeyStore) FindAPIKeyByHash(keyHash string) (*model.APIKey, error) {
+	for _, key := range s.keys {
+		if key.KeyHash == keyHash {
+			return &key, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *apiKeyStore) RevokeAPIKey(email, keyID string) (bool, error) {
+	for i, key := range s.keys {
+		if key.ID == keyID && key.Email == email {
+			now := time.Now()
+			s.keys[i].RevokedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *apiKeyStore) TouchAPIKey(keyID string, interval time.Duration) error {
+	return nil
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := &service.AuthService{MongoRepo: &apiKeyStore{}}
+	router := gin.New()
+	router.POST("/api/chat", AuthMiddleware(auth), RequireScope(model.ScopeChatWrite), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.POST("/api/keys", AuthMiddleware(auth), RequireSession(), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	newKey := func(scopes ...string) (string, model.APIKey) {
+		raw, key, err := auth.CreateAPIKey("alice@example.com", "test", scopes)
+		if err != nil {
+			t.Fatalf("CreateAPIKey: %v", err)
+		}
+		return raw, key
+	}
+	writer, _ := newKey(model.ScopeChatWrite)
+	reader, _ := newKey(model.ScopeChatRead, model.ScopeDatasetsRead)
+	revoked, revokedKey := newKey(model.ScopeChatWrite)
+	if err := auth.RevokeAPIKey("alice@example.com", revokedKey.ID); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		value  string
+		want   int
+	}{
+		{"chat:write key", "/api/chat", "X-API-Key", writer, http.StatusOK},
+		{"chat:write key as bearer token", "/api/chat", "Authorization", "Bearer " + writer, http.StatusOK},
+		{"key without chat:write", "/api/chat", "X-API-Key", reader, http.StatusForbidden},
+		{"revoked key", "/api/chat", "X-API-Key", revoked, http.StatusUnauthorized},
+		{"unknown key", "/api/chat", "X-API-Key", service.APIKeyPrefix + "unknown", http.StatusUnauthorized},
+		{"key minting a key", "/api/keys", "X-API-Key", writer, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, test.path, nil)
+			req.Header.Set(test.header, test.value)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != test.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, test.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+
+	"luma-backend/model"
+	"luma-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HouseholdMiddleware loads the caller's household, if any, so handlers can
+// key shared data by household and RequirePermission can check the role.
+func HouseholdMiddleware(households *service.HouseholdService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		household, err := households.Current(c.GetString("email"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error loading household"})
+			c.Abort()
+			return
+		}
+
+		if household != nil {
+			member, _ := household.Member(c.GetString("email"))
+			c.Set("household", *household)
+			c.Set("household_id", household.ID)
+			c.Set("household_role", string(member.Role))
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission checks the caller's household role. Users outside a
+// household own all of their data and always pass.
+func RequirePermission(permission model.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("household_id") == "" {
+			c.Next()
+			return
+		}
+
+		role := model.HouseholdRole(c.GetString("household_role"))
+		if !role.Can(permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Your household role (" + string(role) + ") does not allow this action"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
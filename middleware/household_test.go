@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"luma-backend/model"
+	"luma-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oneHousehold reports the same household for every member lookup. The
+// embedded HouseholdStore is nil, so any other call panics.
+type oneHousehold struct {
+	service.HouseholdStore
+	household *model.Household
+}
+
+func (s oneHousehold) FindHouseholdByMember(email string) (*model.Household, error) {
+	if s.household == nil {
+		return nil, nil
+	}
+	if _, ok := s.household.Member(email); !ok {
+		return nil, nil
+	}
+	return s.household, nil
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	household := &model.Household{ID: "home", Members: []model.HouseholdMember{
+		{Email: "owner@example.com", Role: model.RoleOwner, Status: model.MemberActive},
+		{Email: "member@example.com", Role: model.RoleMember, Status: model.MemberActive},
+		{Email: "viewer@example.com", Role: model.RoleViewer, Status: model.MemberActive},
+	}}
+	households := &service.HouseholdService{HouseholdRepo: oneHousehold{household: household}}
+
+	permissions := []model.Permission{
+		model.PermissionUploadDataset,
+		model.PermissionChat,
+		model.PermissionViewHistory,
+		model.PermissionManageMembers,
+		model.PermissionManageTariff,
+	}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("email", c.GetHeader("X-Test-Email"))
+	}, HouseholdMiddleware(households))
+	for _, permission := range permissions {
+		router.GET("/"+string(permission), RequirePermission(permission), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+	}
+
+	allowed := map[string][]model.Permission{
+		"owner@example.com":  permissions,
+		"member@example.com": {model.PermissionChat, model.PermissionViewHistory},
+		"viewer@example.com": {model.PermissionViewHistory},
+		"solo@example.com":   permissions,
+	}
+	for email, granted := range allowed {
+		for _, permission := range permissions {
+			want := http.StatusForbidden
+			for _, p := range granted {
+				if p == permission {
+					want = http.StatusOK
+				}
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/"+string(permission), nil)
+			req.Header.Set("X-Test-Email", email)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != want {
+				t.Errorf("%s %s: status = %d, want %d", email, permission, w.Code, want)
+			}
+		}
+	}
+}
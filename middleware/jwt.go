@@ -1,85 +1,138 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"errors"
 	"net/http"
 	"os"
 	"strings"
 
-	"github.com/dgrijalva/jwt-go"
+	"luma-backend/service"
+
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(auth *service.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "You are currently not logged in. Please log in to access this feature."})
-			c.Redirect(http.StatusFound, os.Getenv("FRONTEND_URL"))
-			c.Abort()
+		if rawKey := requestAPIKey(c); rawKey != "" {
+			authenticateAPIKey(c, auth, rawKey)
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format"})
+		tokenString, fromCookie := requestToken(c)
+		if tokenString == "" {
+			if c.GetHeader("Authorization") != "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format"})
+			} else {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "You are currently not logged in. Please log in to access this feature."})
+			}
 			c.Redirect(http.StatusFound, os.Getenv("FRONTEND_URL"))
 			c.Abort()
 			return
 		}
 
-		secretKey := os.Getenv("JWT_SECRET")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(secretKey), nil
-		})
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Redirect(http.StatusFound, os.Getenv("FRONTEND_URL"))
+		if fromCookie && !validCSRF(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Missing or invalid CSRF token"})
 			c.Abort()
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			c.Set("email", claims["email"])
-			c.Set("name", claims["name"])
-			c.Set("picture", claims["picture"])
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		claims, err := auth.Authenticate(tokenString)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrSessionRevoked):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Your session has ended. Please log in again."})
+			case errors.Is(err, service.ErrInvalidToken):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error validating session"})
+				c.Abort()
+				return
+			}
 			c.Redirect(http.StatusFound, os.Getenv("FRONTEND_URL"))
 			c.Abort()
 			return
 		}
 
+		c.Set("email", claims["email"])
+		c.Set("name", claims["name"])
+		c.Set("picture", claims["picture"])
+		c.Set("session_id", claims["sid"])
+
 		c.Next()
 	}
 }
 
-func CheckLoginMiddleware() gin.HandlerFunc {
+func CheckLoginMiddleware(auth *service.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader != "" {
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			if tokenString != authHeader {
-				secretKey := os.Getenv("JWT_SECRET")
-				token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-					if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-						return nil, jwt.ErrSignatureInvalid
-					}
-					return []byte(secretKey), nil
-				})
-				if err == nil {
-					if _, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-						c.JSON(http.StatusOK, gin.H{"message": "User is logged in", "status": "success"})
-						c.Redirect(http.StatusFound, os.Getenv("FRONTEND_CHAT"))
-						c.Abort()
-						return
-					}
-				}
+		if tokenString, _ := requestToken(c); tokenString != "" {
+			if _, err := auth.Authenticate(tokenString); err == nil {
+				c.JSON(http.StatusOK, gin.H{"message": "User is logged in", "status": "success"})
+				c.Redirect(http.StatusFound, os.Getenv("FRONTEND_CHAT"))
+				c.Abort()
+				return
 			}
 		}
 
 		c.Next()
 	}
 }
+
+// requestToken returns the JWT from the Authorization header, or from the
+// jwt_token cookie when no header is sent, and reports which one was used.
+func requestToken(c *gin.Context) (string, bool) {
+	if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		if tokenString == authHeader {
+			return "", false
+		}
+		return tokenString, false
+	}
+
+	tokenString, err := c.Cookie("jwt_token")
+	if err != nil {
+		return "", false
+	}
+	return tokenString, true
+}
+
+// RequireCSRF applies the double-submit check to routes outside
+// AuthMiddleware that act on the auth cookies, such as logout. Requests that
+// authenticate with an Authorization header are not exposed to CSRF.
+func RequireCSRF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" && hasAuthCookie(c) && !validCSRF(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Missing or invalid CSRF token"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func hasAuthCookie(c *gin.Context) bool {
+	for _, name := range []string{"jwt_token", "refresh_token"} {
+		if value, err := c.Cookie(name); err == nil && value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// validCSRF applies the double-submit check to cookie-authenticated requests
+// that change state: the X-CSRF-Token header must echo the csrf_token cookie,
+// which a cross-site page can send but cannot read.
+func validCSRF(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	cookie, err := c.Cookie("csrf_token")
+	if err != nil || cookie == "" {
+		return false
+	}
+	header := c.GetHeader("X-CSRF-Token")
+	return subtle.ConstantTimeCompare([]byte(header), []byte(cookie)) == 1
+}
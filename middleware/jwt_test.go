@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/logout", RequireCSRF(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		cookies       map[string]string
+		csrfHeader    string
+		authorization string
+		want          int
+	}{
+		{"cookie session without token", map[string]string{"jwt_token": "jwt", "csrf_token": "abc"}, "", "", http.StatusForbidden},
+		{"cookie session with wrong token", map[string]string{"jwt_token": "jwt", "csrf_token": "abc"}, "xyz", "", http.StatusForbidden},
+		{"refresh cookie only without token", map[string]string{"refresh_token": "refresh"}, "", "", http.StatusForbidden},
+		{"cookie session with token", map[string]string{"jwt_token": "jwt", "csrf_token": "abc"}, "abc", "", http.StatusOK},
+		{"bearer token", map[string]string{"jwt_token": "jwt"}, "", "Bearer jwt", http.StatusOK},
+		{"no session", nil, "", "", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+			for name, value := range test.cookies {
+				request.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			if test.csrfHeader != "" {
+				request.Header.Set("X-CSRF-Token", test.csrfHeader)
+			}
+			if test.authorization != "" {
+				request.Header.Set("Authorization", test.authorization)
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			if recorder.Code != test.want {
+				t.Errorf("status = %d, want %d", recorder.Code, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package model
+
+type Aggregation struct {
+	Aggregator  string   `json:"aggregator"`
+	Value       *float64 `json:"value"`
+	Cells       []string `json:"cells"`
+	ModelAnswer string   `json:"model_answer"`
+	Match       bool     `json:"match"`
+	Mismatches  []string `json:"mismatches,omitempty"`
+}
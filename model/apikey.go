@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+const (
+	ScopeChatRead      = "chat:read"
+	ScopeChatWrite     = "chat:write"
+	ScopeDatasetsRead  = "datasets:read"
+	ScopeDatasetsWrite = "datasets:write"
+)
+
+var APIKeyScopes = []string{ScopeChatRead, ScopeChatWrite, ScopeDatasetsRead, ScopeDatasetsWrite}
+
+type APIKey struct {
+	ID         string     `json:"id" bson:"key_id"`
+	Email      string     `json:"-" bson:"email"`
+	Name       string     `json:"name" bson:"name"`
+	Prefix     string     `json:"prefix" bson:"prefix"`
+	KeyHash    string     `json:"-" bson:"key_hash"`
+	Scopes     []string   `json:"scopes" bson:"scopes"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
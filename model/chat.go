@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type ChatRequest struct {
 	Text string `json:"text"`
 }
@@ -8,9 +10,28 @@ type Part struct {
 	Text string `json:"text"`
 }
 
+const (
+	SourceUser           = "user"
+	SourceGreeting       = "greeting"
+	SourceTableQA        = "table_qa"
+	SourceRecommendation = "recommendation"
+)
+
 type Message struct {
-	Role  string `json:"role"`
-	Parts []Part `json:"parts"`
+	ID        string    `json:"id,omitempty" bson:"id,omitempty"`
+	Role      string    `json:"role" bson:"role"`
+	Parts     []Part    `json:"parts" bson:"parts"`
+	CreatedAt time.Time `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	Source    string    `json:"source,omitempty" bson:"source,omitempty"`
+	Model     string    `json:"model,omitempty" bson:"model,omitempty"`
+	LatencyMs int64     `json:"latency_ms,omitempty" bson:"latency_ms,omitempty"`
+	Usage     *Usage    `json:"usage,omitempty" bson:"usage,omitempty"`
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens" bson:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens" bson:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens" bson:"total_tokens"`
 }
 
 type ChatHistory struct {
@@ -29,8 +50,16 @@ type Candidate struct {
 	Index        int     `json:"index"`
 }
 
+type UsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
 type APIResponse struct {
-	Candidates []Candidate `json:"candidates"`
+	Candidates    []Candidate    `json:"candidates"`
+	UsageMetadata *UsageMetadata `json:"usageMetadata,omitempty"`
+	ModelVersion  string         `json:"modelVersion,omitempty"`
 }
 
 type Inputs struct {
@@ -45,3 +74,31 @@ type Response struct {
 	Cells       []string `json:"cells"`
 	Aggregator  string   `json:"aggregator"`
 }
+
+type Prompt struct {
+	Query        string              `json:"query"`
+	Table        map[string][]string `json:"table"`
+	TableSummary string              `json:"table_summary,omitempty"`
+	History      []Message           `json:"history"`
+	Summary      string              `json:"summary,omitempty"`
+	Notes        []string            `json:"notes,omitempty"`
+}
+
+type Summary struct {
+	Text      string    `json:"text" bson:"text"`
+	Covered   int       `json:"covered" bson:"covered"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+type ChatHistoryPage struct {
+	Messages   []Message `json:"messages"`
+	HasMore    bool      `json:"has_more"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+type SearchResult struct {
+	ConversationID string  `json:"conversation_id"`
+	Title          string  `json:"title"`
+	Message        Message `json:"message"`
+	Score          float64 `json:"score"`
+}
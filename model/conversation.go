@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+type Conversation struct {
+	ID        string    `json:"id" bson:"conversation_id"`
+	Email     string    `json:"email" bson:"email"`
+	Title     string    `json:"title" bson:"title"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
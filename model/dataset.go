@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+type Dataset struct {
+	Email       string              `json:"email" bson:"email"`
+	HouseholdID string              `json:"household_id,omitempty" bson:"household_id,omitempty"`
+	Name        string              `json:"name" bson:"name"`
+	Table       map[string][]string `json:"table" bson:"table"`
+	Schema      Schema              `json:"schema" bson:"schema"`
+	Rows        int                 `json:"rows" bson:"rows"`
+	UploadedAt  time.Time           `json:"uploaded_at" bson:"uploaded_at"`
+}
+
+type IngestReport struct {
+	Delimiter      string            `json:"delimiter"`
+	Headers        []string          `json:"headers"`
+	RenamedHeaders map[string]string `json:"renamed_headers,omitempty"`
+	AcceptedRows   int               `json:"accepted_rows"`
+	RejectedRows   []RowError        `json:"rejected_rows"`
+}
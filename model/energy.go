@@ -0,0 +1,76 @@
+package model
+
+import "time"
+
+// Column names of the household energy export the analytics endpoints read.
+const (
+	ColumnNameDate        = "Date"
+	ColumnNameTime        = "Time"
+	ColumnNameAppliance   = "Appliance"
+	ColumnNameRoom        = "Room"
+	ColumnNameEnergy      = "Energy_Consumption"
+	ColumnNameStatus      = "Status"
+	ColumnNameTemperature = "Temperature"
+	ColumnNamePeople      = "Number_of_People"
+	ColumnNameSeason      = "Season"
+)
+
+type EnergyRecord struct {
+	Timestamp   time.Time
+	Appliance   string
+	Room        string
+	Status      string
+	Season      string
+	KWh         float64
+	Temperature *float64
+	People      *float64
+}
+
+type SeriesPoint struct {
+	Start time.Time `json:"start"`
+	KWh   float64   `json:"kwh"`
+}
+
+type Series struct {
+	Key      string        `json:"key"`
+	TotalKWh float64       `json:"total_kwh"`
+	Points   []SeriesPoint `json:"points"`
+}
+
+type EnergySeries struct {
+	GroupBy  string    `json:"group_by"`
+	Interval string    `json:"interval"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Unit     string    `json:"unit"`
+	TotalKWh float64   `json:"total_kwh"`
+	Series   []Series  `json:"series"`
+}
+
+type ForecastPoint struct {
+	Start time.Time `json:"start"`
+	KWh   float64   `json:"kwh"`
+	Lower float64   `json:"lower"`
+	Upper float64   `json:"upper"`
+}
+
+type ForecastSeries struct {
+	Key           string             `json:"key"`
+	Method        string             `json:"method"`
+	Total         ForecastPoint      `json:"total"`
+	Points        []ForecastPoint    `json:"points"`
+	Coefficients  map[string]float64 `json:"coefficients,omitempty"`
+	ValidationMAE *float64           `json:"validation_mae,omitempty"`
+}
+
+type Forecast struct {
+	GroupBy      string           `json:"group_by"`
+	Horizon      string           `json:"horizon"`
+	Level        float64          `json:"level"`
+	LastObserved time.Time        `json:"last_observed"`
+	From         time.Time        `json:"from"`
+	To           time.Time        `json:"to"`
+	Unit         string           `json:"unit"`
+	Total        ForecastPoint    `json:"total"`
+	Series       []ForecastSeries `json:"series"`
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+type HouseholdRole string
+
+const (
+	RoleOwner  HouseholdRole = "owner"
+	RoleMember HouseholdRole = "member"
+	RoleViewer HouseholdRole = "viewer"
+)
+
+const (
+	MemberInvited = "invited"
+	MemberActive  = "active"
+)
+
+type Permission string
+
+const (
+	PermissionUploadDataset Permission = "upload_dataset"
+	PermissionChat          Permission = "chat"
+	PermissionViewHistory   Permission = "view_history"
+	PermissionManageMembers Permission = "manage_members"
+	PermissionManageTariff  Permission = "manage_tariff"
+)
+
+var rolePermissions = map[HouseholdRole][]Permission{
+	RoleOwner:  {PermissionUploadDataset, PermissionChat, PermissionViewHistory, PermissionManageMembers, PermissionManageTariff},
+	RoleMember: {PermissionChat, PermissionViewHistory},
+	RoleViewer: {PermissionViewHistory},
+}
+
+func (r HouseholdRole) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+func (r HouseholdRole) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type HouseholdMember struct {
+	Email     string        `json:"email" bson:"email"`
+	Role      HouseholdRole `json:"role" bson:"role"`
+	Status    string        `json:"status" bson:"status"`
+	InvitedBy string        `json:"invited_by,omitempty" bson:"invited_by,omitempty"`
+	InvitedAt time.Time     `json:"invited_at" bson:"invited_at"`
+	JoinedAt  *time.Time    `json:"joined_at,omitempty" bson:"joined_at,omitempty"`
+}
+
+type Household struct {
+	ID        string            `json:"id" bson:"household_id"`
+	Name      string            `json:"name" bson:"name"`
+	Members   []HouseholdMember `json:"members" bson:"members"`
+	CreatedAt time.Time         `json:"created_at" bson:"created_at"`
+}
+
+func (h Household) Member(email string) (HouseholdMember, bool) {
+	for _, member := range h.Members {
+		if strings.EqualFold(member.Email, email) {
+			return member, true
+		}
+	}
+	return HouseholdMember{}, false
+}
+
+func (h Household) ActiveEmails() []string {
+	emails := make([]string, 0, len(h.Members))
+	for _, member := range h.Members {
+		if member.Status == MemberActive {
+			emails = append(emails, member.Email)
+		}
+	}
+	return emails
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Identity links a User to an account at an external login provider.
+type Identity struct {
+	Provider string    `json:"provider" bson:"provider"`
+	Subject  string    `json:"subject" bson:"subject"`
+	Email    string    `json:"email" bson:"email"`
+	LinkedAt time.Time `json:"linked_at" bson:"linked_at"`
+}
+
+// ExternalProfile is what a login provider reports about the signed-in user.
+type ExternalProfile struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
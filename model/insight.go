@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+type Anomaly struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Appliance   string    `json:"appliance"`
+	Room        string    `json:"room"`
+	Season      string    `json:"season,omitempty"`
+	KWh         float64   `json:"kwh"`
+	ExpectedKWh float64   `json:"expected_kwh"`
+	Score       float64   `json:"score"`
+	Direction   string    `json:"direction"`
+	Baseline    string    `json:"baseline"`
+	Samples     int       `json:"samples"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	Explanation string    `json:"explanation"`
+}
+
+type StandbyFinding struct {
+	Rank                 int     `json:"rank"`
+	Appliance            string  `json:"appliance"`
+	Room                 string  `json:"room"`
+	OffReadings          int     `json:"off_readings"`
+	IdleReadings         int     `json:"idle_readings"`
+	WastedKWh            float64 `json:"wasted_kwh"`
+	WastedCost           float64 `json:"wasted_cost"`
+	ShareOfUsage         float64 `json:"share_of_usage"`
+	ProjectedMonthlyKWh  float64 `json:"projected_monthly_kwh"`
+	ProjectedMonthlyCost float64 `json:"projected_monthly_cost"`
+	Recommendation       string  `json:"recommendation"`
+}
+
+type StandbyReport struct {
+	From                 time.Time        `json:"from"`
+	To                   time.Time        `json:"to"`
+	Currency             string           `json:"currency"`
+	TotalWastedKWh       float64          `json:"total_wasted_kwh"`
+	TotalWastedCost      float64          `json:"total_wasted_cost"`
+	ProjectedMonthlyCost float64          `json:"projected_monthly_cost"`
+	Findings             []StandbyFinding `json:"findings"`
+}
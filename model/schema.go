@@ -0,0 +1,63 @@
+package model
+
+import "time"
+
+type ColumnType string
+
+const (
+	ColumnDate        ColumnType = "date"
+	ColumnTime        ColumnType = "time"
+	ColumnFloat       ColumnType = "float"
+	ColumnInt         ColumnType = "int"
+	ColumnCategorical ColumnType = "categorical"
+)
+
+type Column struct {
+	Name         string     `json:"name" bson:"name"`
+	Type         ColumnType `json:"type" bson:"type"`
+	DecimalComma bool       `json:"decimal_comma,omitempty" bson:"decimal_comma,omitempty"`
+}
+
+type Schema struct {
+	Columns []Column `json:"columns" bson:"columns"`
+}
+
+type Value struct {
+	Raw    string    `json:"raw"`
+	Number float64   `json:"number,omitempty"`
+	Time   time.Time `json:"time,omitempty"`
+	Valid  bool      `json:"valid"`
+}
+
+type RowError struct {
+	Line   int    `json:"line" bson:"line"`
+	Column string `json:"column,omitempty" bson:"column,omitempty"`
+	Reason string `json:"reason" bson:"reason"`
+}
+
+type TypedTable struct {
+	Schema Schema    `json:"schema"`
+	Rows   [][]Value `json:"rows"`
+}
+
+func (s Schema) ColumnIndex(name string) int {
+	for i, column := range s.Columns {
+		if column.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t TypedTable) Column(name string) []Value {
+	index := t.Schema.ColumnIndex(name)
+	if index < 0 {
+		return nil
+	}
+
+	values := make([]Value, 0, len(t.Rows))
+	for _, row := range t.Rows {
+		values = append(values, row[index])
+	}
+	return values
+}
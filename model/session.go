@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+type Session struct {
+	ID        string     `json:"id" bson:"session_id"`
+	User      User       `json:"-" bson:"user"`
+	UserAgent string     `json:"user_agent" bson:"user_agent,omitempty"`
+	IP        string     `json:"ip" bson:"ip,omitempty"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" bson:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	Current   bool       `json:"current" bson:"-"`
+}
@@ -0,0 +1,74 @@
+package model
+
+import "time"
+
+const (
+	TariffFlat      = "flat"
+	TariffTimeOfUse = "time_of_use"
+	TariffTiered    = "tiered"
+)
+
+// TariffBand prices consumption between Start and End ("HH:MM", local time of
+// the dataset). A band whose End is before its Start wraps past midnight.
+type TariffBand struct {
+	Name  string  `json:"name" bson:"name"`
+	Start string  `json:"start" bson:"start"`
+	End   string  `json:"end" bson:"end"`
+	Rate  float64 `json:"rate" bson:"rate"`
+}
+
+// TariffTier prices a block of each month's consumption. The last tier may
+// leave UpToKWh unset to cover everything above the previous block.
+type TariffTier struct {
+	UpToKWh *float64 `json:"up_to_kwh,omitempty" bson:"up_to_kwh,omitempty"`
+	Rate    float64  `json:"rate" bson:"rate"`
+}
+
+type Tariff struct {
+	Email        string       `json:"-" bson:"email"`
+	HouseholdID  string       `json:"-" bson:"household_id,omitempty"`
+	Name         string       `json:"name" bson:"name"`
+	Type         string       `json:"type" bson:"type"`
+	Currency     string       `json:"currency" bson:"currency"`
+	FlatRate     float64      `json:"flat_rate,omitempty" bson:"flat_rate,omitempty"`
+	Bands        []TariffBand `json:"bands,omitempty" bson:"bands,omitempty"`
+	Tiers        []TariffTier `json:"tiers,omitempty" bson:"tiers,omitempty"`
+	TaxPercent   float64      `json:"tax_percent" bson:"tax_percent"`
+	FixedMonthly float64      `json:"fixed_monthly" bson:"fixed_monthly"`
+	Default      bool         `json:"default" bson:"-"`
+	UpdatedAt    time.Time    `json:"updated_at" bson:"updated_at"`
+}
+
+// CostedRecord is one dataset row priced under the household tariff. Cost
+// includes tax; fixed charges are only added to monthly totals.
+type CostedRecord struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Appliance  string    `json:"appliance"`
+	Room       string    `json:"room"`
+	KWh        float64   `json:"kwh"`
+	Rate       float64   `json:"rate"`
+	EnergyCost float64   `json:"energy_cost"`
+	Cost       float64   `json:"cost"`
+}
+
+type CostBreakdown struct {
+	Key  string  `json:"key"`
+	KWh  float64 `json:"kwh"`
+	Cost float64 `json:"cost"`
+}
+
+type CostReport struct {
+	Tariff       Tariff          `json:"tariff"`
+	Currency     string          `json:"currency"`
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	TotalKWh     float64         `json:"total_kwh"`
+	EnergyCost   float64         `json:"energy_cost"`
+	FixedCharges float64         `json:"fixed_charges"`
+	Tax          float64         `json:"tax"`
+	TotalCost    float64         `json:"total_cost"`
+	ByAppliance  []CostBreakdown `json:"by_appliance"`
+	ByRoom       []CostBreakdown `json:"by_room"`
+	ByMonth      []CostBreakdown `json:"by_month"`
+	Records      []CostedRecord  `json:"records,omitempty"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+type RefreshToken struct {
+	TokenHash string     `bson:"token_hash"`
+	FamilyID  string     `bson:"family_id"`
+	Email     string     `bson:"email"`
+	CreatedAt time.Time  `bson:"created_at"`
+	ExpiresAt time.Time  `bson:"expires_at"`
+	UsedAt    *time.Time `bson:"used_at,omitempty"`
+	RevokedAt *time.Time `bson:"revoked_at,omitempty"`
+}
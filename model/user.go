@@ -1,7 +1,8 @@
 package model
 
 type User struct {
-	Email   string `bson:"email"`
-	Name    string `bson:"name"`
-	Picture string `bson:"profile_picture"`
+	Email      string     `bson:"email"`
+	Name       string     `bson:"name"`
+	Picture    string     `bson:"profile_picture"`
+	Identities []Identity `bson:"identities,omitempty"`
 }
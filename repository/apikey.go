@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"luma-backend/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (r *MongoRepository) SaveAPIKey(key model.APIKey) error {
+	collection := r.DB.Collection("api_keys")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, key)
+	return err
+}
+
+func (r *MongoRepository) FindAPIKeyByHash(keyHash string) (*model.APIKey, error) {
+	collection := r.DB.Collection("api_keys")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var key model.APIKey
+	err := collection.FindOne(ctx, bson.M{"key_hash": keyHash}).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *MongoRepository) ListAPIKeys(email string) ([]model.APIKey, error) {
+	collection := r.DB.Collection("api_keys")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"email": email, "revoked_at": bson.M{"$exists": false}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []model.APIKey{}
+	err = cursor.All(ctx, &keys)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *MongoRepository) RevokeAPIKey(email, keyID string) (bool, error) {
+	collection := r.DB.Collection("api_keys")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"key_id": keyID, "email": email, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revoked_at": time.Now()}}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// TouchAPIKey records that a key was used. Writes are skipped while the stored
+// timestamp is newer than the given interval so busy scripts do not turn
+// every request into an update.
+func (r *MongoRepository) TouchAPIKey(keyID string, interval time.Duration) error {
+	collection := r.DB.Collection("api_keys")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{
+		"key_id": keyID,
+		"$or": bson.A{
+			bson.M{"last_used_at": bson.M{"$exists": false}},
+			bson.M{"last_used_at": bson.M{"$lt": now.Add(-interval)}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"last_used_at": now}}
+
+	_, err := collection.UpdateOne(ctx, filter, update)
+	return err
+}
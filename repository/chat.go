@@ -11,6 +11,24 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ChatStore is the chat persistence the services depend on. ChatRepository
+// keeps it in MongoDB; MemoryChatRepository keeps it in process for offline
+// runs and tests.
+type ChatStore interface {
+	SaveMessage(sessionID string, message model.Message) error
+	GetMessages(sessionID string) ([]model.Message, error)
+	GetMessagesPage(sessionID string, before, limit int) ([]model.Message, []int, bool, error)
+	SaveSummary(sessionID string, summary model.Summary) error
+	GetSummary(sessionID string) (model.Summary, error)
+	CreateConversation(conversation model.Conversation) error
+	GetConversation(conversationID string) (*model.Conversation, error)
+	ListConversations(emails []string) ([]model.Conversation, error)
+	RenameConversation(email, conversationID, title string) (bool, error)
+	TouchConversation(conversationID, title string) error
+	DeleteConversation(email, conversationID string) (bool, error)
+	SearchMessages(sessionIDs []string, query string, limit int) ([]model.SearchResult, error)
+}
+
 type ChatRepository struct {
 	Client *mongo.Client
 	DB     *mongo.Database
@@ -57,3 +75,233 @@ func (r *ChatRepository) GetMessages(sessionID string) ([]model.Message, error)
 
 	return result.Messages, nil
 }
+
+func (r *ChatRepository) SaveSummary(sessionID string, summary model.Summary) error {
+	collection := r.DB.Collection("chat_history")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"session_id": sessionID}
+	update := bson.M{"$set": bson.M{"summary": summary}}
+
+	_, err := collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *ChatRepository) GetSummary(sessionID string) (model.Summary, error) {
+	collection := r.DB.Collection("chat_history")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"session_id": sessionID}
+	opts := options.FindOne().SetProjection(bson.M{"summary": 1})
+	var result struct {
+		Summary model.Summary `bson:"summary"`
+	}
+
+	err := collection.FindOne(ctx, filter, opts).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.Summary{}, nil
+		}
+		return model.Summary{}, err
+	}
+
+	return result.Summary, nil
+}
+
+func (r *ChatRepository) CreateConversation(conversation model.Conversation) error {
+	collection := r.DB.Collection("conversations")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, conversation)
+	return err
+}
+
+func (r *ChatRepository) GetConversation(conversationID string) (*model.Conversation, error) {
+	collection := r.DB.Collection("conversations")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var conversation model.Conversation
+	err := collection.FindOne(ctx, bson.M{"conversation_id": conversationID}).Decode(&conversation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &conversation, nil
+}
+
+func (r *ChatRepository) ListConversations(emails []string) ([]model.Conversation, error) {
+	collection := r.DB.Collection("conversations")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}})
+	cursor, err := collection.Find(ctx, bson.M{"email": bson.M{"$in": emails}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	conversations := make([]model.Conversation, 0)
+	err = cursor.All(ctx, &conversations)
+	return conversations, err
+}
+
+func (r *ChatRepository) RenameConversation(email, conversationID, title string) (bool, error) {
+	collection := r.DB.Collection("conversations")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"conversation_id": conversationID, "email": email}
+	update := bson.M{"$set": bson.M{"title": title, "updated_at": time.Now()}}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// TouchConversation bumps the conversation's activity time and gives it a
+// title if it does not have one yet.
+func (r *ChatRepository) TouchConversation(conversationID, title string) error {
+	collection := r.DB.Collection("conversations")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"conversation_id": conversationID, "title": ""},
+		bson.M{"$set": bson.M{"title": title}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = collection.UpdateOne(ctx,
+		bson.M{"conversation_id": conversationID},
+		bson.M{"$set": bson.M{"updated_at": now}},
+	)
+	return err
+}
+
+func (r *ChatRepository) DeleteConversation(email, conversationID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.DB.Collection("conversations").DeleteOne(ctx, bson.M{"conversation_id": conversationID, "email": email})
+	if err != nil {
+		return false, err
+	}
+	if result.DeletedCount == 0 {
+		return false, nil
+	}
+
+	_, err = r.DB.Collection("chat_history").DeleteOne(ctx, bson.M{"session_id": conversationID})
+	return true, err
+}
+
+func (r *ChatRepository) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := r.DB.Collection("chat_history").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "session_id", Value: 1}}},
+		{Keys: bson.D{{Key: "messages.parts.text", Value: "text"}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB.Collection("conversations").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "conversation_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "updated_at", Value: -1}}},
+	})
+	return err
+}
+
+// GetMessagesPage returns up to limit messages older than the array position
+// before (all messages when before is negative), oldest first, and the
+// positions they were stored at. Messages are only ever appended, so positions
+// are stable cursors.
+func (r *ChatRepository) GetMessagesPage(sessionID string, before, limit int) ([]model.Message, []int, bool, error) {
+	collection := r.DB.Collection("chat_history")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"session_id": sessionID}}},
+		{{Key: "$project", Value: bson.M{"messages": 1}}},
+		{{Key: "$unwind", Value: bson.M{"path": "$messages", "includeArrayIndex": "position"}}},
+	}
+	if before >= 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"position": bson.M{"$lt": before}}}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.M{"position": -1}}},
+		bson.D{{Key: "$limit", Value: limit + 1}},
+	)
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		Message  model.Message `bson:"messages"`
+		Position int           `bson:"position"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, nil, false, err
+	}
+
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+
+	messages := make([]model.Message, len(rows))
+	positions := make([]int, len(rows))
+	for i, row := range rows {
+		messages[len(rows)-1-i] = row.Message
+		positions[len(rows)-1-i] = row.Position
+	}
+	return messages, positions, hasMore, nil
+}
+
+// SearchMessages finds the conversations matching query with the text index,
+// then ranks their messages one by one with the index's own tokenisation, so
+// each result's score is for that message rather than its conversation.
+func (r *ChatRepository) SearchMessages(sessionIDs []string, query string, limit int) ([]model.SearchResult, error) {
+	collection := r.DB.Collection("chat_history")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"$text":      bson.M{"$search": query},
+			"session_id": bson.M{"$in": sessionIDs},
+		}}},
+		{{Key: "$project", Value: bson.M{"session_id": 1, "messages": 1}}},
+		{{Key: "$unwind", Value: bson.M{"path": "$messages", "includeArrayIndex": "position"}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var candidates []searchCandidate
+	if err := cursor.All(ctx, &candidates); err != nil {
+		return nil, err
+	}
+	return rankMessages(candidates, query, limit), nil
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"luma-backend/model"
+)
+
+// MemoryChatRepository is an in-process ChatStore. Together with
+// FakeProvider it lets the chat flow run without MongoDB or network access.
+type MemoryChatRepository struct {
+	mu            sync.Mutex
+	messages      map[string][]model.Message
+	summaries     map[string]model.Summary
+	conversations map[string]model.Conversation
+}
+
+func NewMemoryChatRepository() *MemoryChatRepository {
+	return &MemoryChatRepository{
+		messages:      make(map[string][]model.Message),
+		summaries:     make(map[string]model.Summary),
+		conversations: make(map[string]model.Conversation),
+	}
+}
+
+func (r *MemoryChatRepository) SaveMessage(sessionID string, message model.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages[sessionID] = append(r.messages[sessionID], message)
+	return nil
+}
+
+func (r *MemoryChatRepository) GetMessages(sessionID string) ([]model.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	messages := r.messages[sessionID]
+	if messages == nil {
+		return nil, nil
+	}
+	return append([]model.Message(nil), messages...), nil
+}
+
+func (r *MemoryChatRepository) GetMessagesPage(sessionID string, before, limit int) ([]model.Message, []int, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	end := len(r.messages[sessionID])
+	if before >= 0 && before < end {
+		end = before
+	}
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+
+	messages := append([]model.Message(nil), r.messages[sessionID][start:end]...)
+	positions := make([]int, 0, end-start)
+	for position := start; position < end; position++ {
+		positions = append(positions, position)
+	}
+	return messages, positions, start > 0, nil
+}
+
+func (r *MemoryChatRepository) SaveSummary(sessionID string, summary model.Summary) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.messages[sessionID]; ok {
+		r.summaries[sessionID] = summary
+	}
+	return nil
+}
+
+func (r *MemoryChatRepository) GetSummary(sessionID string) (model.Summary, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.summaries[sessionID], nil
+}
+
+func (r *MemoryChatRepository) CreateConversation(conversation model.Conversation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.conversations[conversation.ID] = conversation
+	return nil
+}
+
+func (r *MemoryChatRepository) GetConversation(conversationID string) (*model.Conversation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conversation, ok := r.conversations[conversationID]
+	if !ok {
+		return nil, nil
+	}
+	return &conversation, nil
+}
+
+func (r *MemoryChatRepository) ListConversations(emails []string) ([]model.Conversation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conversations := make([]model.Conversation, 0)
+	for _, conversation := range r.conversations {
+		for _, email := range emails {
+			if conversation.Email == email {
+				conversations = append(conversations, conversation)
+				break
+			}
+		}
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].UpdatedAt.After(conversations[j].UpdatedAt)
+	})
+	return conversations, nil
+}
+
+func (r *MemoryChatRepository) RenameConversation(email, conversationID, title string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conversation, ok := r.conversations[conversationID]
+	if !ok || conversation.Email != email {
+		return false, nil
+	}
+	conversation.Title = title
+	conversation.UpdatedAt = time.Now()
+	r.conversations[conversationID] = conversation
+	return true, nil
+}
+
+func (r *MemoryChatRepository) TouchConversation(conversationID, title string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conversation, ok := r.conversations[conversationID]
+	if !ok {
+		return nil
+	}
+	if conversation.Title == "" {
+		conversation.Title = title
+	}
+	conversation.UpdatedAt = time.Now()
+	r.conversations[conversationID] = conversation
+	return nil
+}
+
+func (r *MemoryChatRepository) DeleteConversation(email, conversationID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conversation, ok := r.conversations[conversationID]
+	if !ok || conversation.Email != email {
+		return false, nil
+	}
+	delete(r.conversations, conversationID)
+	delete(r.messages, conversationID)
+	delete(r.summaries, conversationID)
+	return true, nil
+}
+
+// SearchMessages ranks messages like ChatRepository does, without the text
+// index's conversation-level prefilter.
+func (r *MemoryChatRepository) SearchMessages(sessionIDs []string, query string, limit int) ([]model.SearchResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var candidates []searchCandidate
+	for _, sessionID := range sessionIDs {
+		for i, message := range r.messages[sessionID] {
+			candidates = append(candidates, searchCandidate{SessionID: sessionID, Message: message, Position: i})
+		}
+	}
+	return rankMessages(candidates, query, limit), nil
+}
+
+func partsText(parts []model.Part) string {
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteString(part.Text)
+	}
+	return b.String()
+}
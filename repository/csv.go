@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"luma-backend/model"
+)
+
+var (
+	ErrEmptyCSV = errors.New("CSV file is empty")
+	ErrNoRows   = errors.New("CSV file has no valid data rows")
+)
+
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+func ParseCSV(data string) (map[string][]string, model.IngestReport, error) {
+	data = strings.TrimPrefix(data, "\ufeff")
+	if strings.TrimSpace(data) == "" {
+		return nil, model.IngestReport{}, ErrEmptyCSV
+	}
+
+	delimiter := detectDelimiter(data)
+	report := model.IngestReport{Delimiter: string(delimiter)}
+
+	r := csv.NewReader(strings.NewReader(data))
+	r.Comma = delimiter
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+
+	rawHeaders, err := r.Read()
+	if err == io.EOF {
+		return nil, report, ErrEmptyCSV
+	}
+	if err != nil {
+		return nil, report, fmt.Errorf("invalid header row: %w", err)
+	}
+
+	headers, renamed := normalizeHeaders(rawHeaders)
+	report.Headers = headers
+	if len(renamed) > 0 {
+		report.RenamedHeaders = renamed
+	}
+
+	table := make(map[string][]string, len(headers))
+	for _, header := range headers {
+		table[header] = make([]string, 0)
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				report.RejectedRows = append(report.RejectedRows, model.RowError{
+					Line:   parseErr.StartLine,
+					Reason: parseErr.Err.Error(),
+				})
+				continue
+			}
+			return nil, report, err
+		}
+
+		line, _ := r.FieldPos(0)
+		if isBlankRecord(record) {
+			continue
+		}
+
+		record = trimTrailingEmpty(record, len(headers))
+		if len(record) != len(headers) {
+			report.RejectedRows = append(report.RejectedRows, model.RowError{
+				Line:   line,
+				Reason: fmt.Sprintf("row has %d fields, expected %d", len(record), len(headers)),
+			})
+			continue
+		}
+
+		for i, value := range record {
+			table[headers[i]] = append(table[headers[i]], strings.TrimSpace(value))
+		}
+		report.AcceptedRows++
+	}
+
+	if report.AcceptedRows == 0 {
+		return nil, report, ErrNoRows
+	}
+
+	return table, report, nil
+}
+
+func detectDelimiter(data string) rune {
+	var header string
+	for _, line := range strings.Split(data, "\n") {
+		if strings.TrimSpace(line) != "" {
+			header = line
+			break
+		}
+	}
+
+	best, bestCount := ',', 0
+	for _, delimiter := range csvDelimiters {
+		count := 0
+		inQuotes := false
+		for _, ch := range header {
+			switch {
+			case ch == '"':
+				inQuotes = !inQuotes
+			case ch == delimiter && !inQuotes:
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = delimiter, count
+		}
+	}
+	return best
+}
+
+func normalizeHeaders(raw []string) ([]string, map[string]string) {
+	headers := make([]string, len(raw))
+	used := make(map[string]bool, len(raw))
+	renamed := make(map[string]string)
+
+	for i, header := range raw {
+		base := strings.TrimSpace(header)
+		if base == "" {
+			base = "Column_" + strconv.Itoa(i+1)
+		}
+
+		name := base
+		for n := 2; used[name]; n++ {
+			name = base + "_" + strconv.Itoa(n)
+		}
+		used[name] = true
+
+		if name != strings.TrimSpace(header) {
+			renamed[name] = header
+		}
+		headers[i] = name
+	}
+
+	return headers, renamed
+}
+
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func trimTrailingEmpty(record []string, want int) []string {
+	for len(record) > want && strings.TrimSpace(record[len(record)-1]) == "" {
+		record = record[:len(record)-1]
+	}
+	return record
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"luma-backend/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DatasetRepository struct {
+	Client *mongo.Client
+	DB     *mongo.Database
+}
+
+func NewDatasetRepository(client *mongo.Client, dbName string) *DatasetRepository {
+	db := client.Database(dbName)
+	return &DatasetRepository{
+		Client: client,
+		DB:     db,
+	}
+}
+
+func (r *DatasetRepository) SaveDataset(dataset model.Dataset) error {
+	collection := r.DB.Collection("datasets")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := datasetFilter(dataset.Email, dataset.HouseholdID)
+	opts := options.Replace().SetUpsert(true)
+
+	_, err := collection.ReplaceOne(ctx, filter, dataset, opts)
+	return err
+}
+
+func (r *DatasetRepository) GetDataset(email, householdID string) (*model.Dataset, error) {
+	collection := r.DB.Collection("datasets")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var dataset model.Dataset
+	err := collection.FindOne(ctx, datasetFilter(email, householdID)).Decode(&dataset)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &dataset, nil
+}
+
+// datasetFilter selects the household's shared dataset, or the user's own
+// dataset when they do not belong to a household.
+func datasetFilter(email, householdID string) bson.M {
+	if householdID != "" {
+		return bson.M{"household_id": householdID}
+	}
+	return bson.M{"email": email, "household_id": bson.M{"$exists": false}}
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"luma-backend/model"
+)
+
+// FakeProvider answers table questions and recommendations in-process with
+// deterministic heuristics, so the chat flow can run without network access.
+type FakeProvider struct{}
+
+func (p *FakeProvider) ModelName() string {
+	return "fake"
+}
+
+func (p *FakeProvider) AnswerTable(inputs model.Inputs) (model.Response, error) {
+	columns := make([]string, 0, len(inputs.Table))
+	for column := range inputs.Table {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	if len(columns) == 0 {
+		return model.Response{Aggregator: "NONE"}, nil
+	}
+
+	query := strings.ToLower(inputs.Query)
+	target := fakeTargetColumn(query, columns)
+	values := inputs.Table[columns[target]]
+
+	aggregator := "NONE"
+	switch {
+	case strings.Contains(query, "average") || strings.Contains(query, "rata"):
+		aggregator = "AVERAGE"
+	case strings.Contains(query, "how many") || strings.Contains(query, "berapa banyak") || strings.Contains(query, "count"):
+		aggregator = "COUNT"
+	case strings.Contains(query, "total") || strings.Contains(query, "jumlah") || strings.Contains(query, "sum"):
+		aggregator = "SUM"
+	}
+
+	rows := len(values)
+	if aggregator == "NONE" && rows > 1 {
+		rows = 1
+	}
+
+	response := model.Response{
+		Aggregator:  aggregator,
+		Coordinates: make([][]int, 0, rows),
+		Cells:       make([]string, 0, rows),
+	}
+	for i := 0; i < rows; i++ {
+		response.Coordinates = append(response.Coordinates, []int{i, target})
+		response.Cells = append(response.Cells, values[i])
+	}
+
+	response.Answer = strings.Join(response.Cells, ", ")
+	if aggregator != "NONE" {
+		response.Answer = aggregator + " > " + response.Answer
+	}
+
+	return response, nil
+}
+
+func fakeTargetColumn(query string, columns []string) int {
+	fallback := 0
+	for i, column := range columns {
+		name := strings.ToLower(strings.ReplaceAll(column, "_", " "))
+		if strings.Contains(query, name) {
+			return i
+		}
+		if column == "Energy_Consumption" {
+			fallback = i
+		}
+	}
+	return fallback
+}
+
+func (p *FakeProvider) Recommend(prompt model.Prompt) (model.APIResponse, error) {
+	text := fmt.Sprintf("[offline] Rekomendasi untuk %q berdasarkan %d kolom data dan %d pesan sebelumnya.", prompt.Query, len(prompt.Table), len(prompt.History))
+
+	return model.APIResponse{
+		ModelVersion: p.ModelName(),
+		UsageMetadata: &model.UsageMetadata{
+			PromptTokenCount:     len(prompt.Query) / 4,
+			CandidatesTokenCount: len(text) / 4,
+			TotalTokenCount:      (len(prompt.Query) + len(text)) / 4,
+		},
+		Candidates: []model.Candidate{
+			{
+				Content: model.Content{
+					Role:  "model",
+					Parts: []model.Part{{Text: text}},
+				},
+				FinishReason: "STOP",
+			},
+		},
+	}, nil
+}
+
+func (p *FakeProvider) StreamRecommend(ctx context.Context, prompt model.Prompt, onChunk func(string) error) (model.APIResponse, error) {
+	response, err := p.Recommend(prompt)
+	if err != nil {
+		return model.APIResponse{}, err
+	}
+
+	var sent strings.Builder
+	for _, word := range strings.SplitAfter(response.Candidates[0].Content.Parts[0].Text, " ") {
+		if err := ctx.Err(); err != nil {
+			response.Candidates[0].Content.Parts[0].Text = sent.String()
+			return response, err
+		}
+		sent.WriteString(word)
+		if err := onChunk(word); err != nil {
+			response.Candidates[0].Content.Parts[0].Text = sent.String()
+			return response, err
+		}
+	}
+	return response, nil
+}
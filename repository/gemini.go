@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"luma-backend/model"
+)
+
+const (
+	DefaultGeminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+	DefaultGeminiModel   = "gemini-1.5-flash-latest"
+)
+
+const geminiPersona = "Kamu adalah Luma, AI Assistant yang membantu pengguna memahami dan menghemat penggunaan energi di Smarthome mereka. Jawab dalam bahasa yang digunakan pengguna, berikan rekomendasi yang konkret, dan dasarkan analisis hanya pada data rumah tangga berikut."
+
+const geminiSummaryInstruction = "Ringkas percakapan antara pengguna dan Luma berikut dalam maksimal 10 poin singkat. Pertahankan angka, nama peralatan, ruangan, dan keputusan penting. Gabungkan dengan ringkasan sebelumnya jika ada."
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []model.Part `json:"parts"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent  `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent `json:"contents"`
+}
+
+type GeminiProvider struct {
+	Client  *http.Client
+	BaseURL string
+	Model   string
+	APIKey  string
+}
+
+func (p *GeminiProvider) ModelName() string {
+	return p.Model
+}
+
+func (p *GeminiProvider) Recommend(prompt model.Prompt) (model.APIResponse, error) {
+	resp, err := p.post(context.Background(), "generateContent", "", buildGeminiRequest(prompt))
+	if err != nil {
+		return model.APIResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var geminiResponse model.APIResponse
+	err = json.NewDecoder(resp.Body).Decode(&geminiResponse)
+	if err != nil {
+		return model.APIResponse{}, err
+	}
+
+	return geminiResponse, nil
+}
+
+func (p *GeminiProvider) StreamRecommend(ctx context.Context, prompt model.Prompt, onChunk func(string) error) (model.APIResponse, error) {
+	resp, err := p.post(ctx, "streamGenerateContent", "alt=sse&", buildGeminiRequest(prompt))
+	if err != nil {
+		return model.APIResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var full strings.Builder
+	assembled := model.APIResponse{}
+	finishReason := ""
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var chunk model.APIResponse
+		err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &chunk)
+		if err != nil {
+			return assembledResponse(assembled, full.String(), finishReason), err
+		}
+		if chunk.UsageMetadata != nil {
+			assembled.UsageMetadata = chunk.UsageMetadata
+		}
+		if chunk.ModelVersion != "" {
+			assembled.ModelVersion = chunk.ModelVersion
+		}
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+		if chunk.Candidates[0].FinishReason != "" {
+			finishReason = chunk.Candidates[0].FinishReason
+		}
+
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			if part.Text == "" {
+				continue
+			}
+			full.WriteString(part.Text)
+			if err := onChunk(part.Text); err != nil {
+				return assembledResponse(assembled, full.String(), finishReason), err
+			}
+		}
+	}
+
+	return assembledResponse(assembled, full.String(), finishReason), scanner.Err()
+}
+
+func assembledResponse(response model.APIResponse, text, finishReason string) model.APIResponse {
+	response.Candidates = []model.Candidate{{
+		Content:      model.Content{Role: "model", Parts: []model.Part{{Text: text}}},
+		FinishReason: finishReason,
+	}}
+	return response
+}
+
+func (p *GeminiProvider) Summarize(previous string, messages []model.Message) (string, error) {
+	var transcript strings.Builder
+	if previous != "" {
+		transcript.WriteString("Ringkasan sebelumnya:\n" + previous + "\n\n")
+	}
+	transcript.WriteString("Percakapan lanjutan:\n")
+	for _, message := range messages {
+		for _, part := range message.Parts {
+			transcript.WriteString(message.Role + ": " + part.Text + "\n")
+		}
+	}
+
+	payload := geminiRequest{
+		SystemInstruction: &geminiContent{Parts: []model.Part{{Text: geminiSummaryInstruction}}},
+		Contents:          []geminiContent{{Role: "user", Parts: []model.Part{{Text: transcript.String()}}}},
+	}
+
+	resp, err := p.post(context.Background(), "generateContent", "", payload)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var geminiResponse model.APIResponse
+	err = json.NewDecoder(resp.Body).Decode(&geminiResponse)
+	if err != nil {
+		return "", err
+	}
+	if len(geminiResponse.Candidates) == 0 {
+		return "", fmt.Errorf("gemini returned no summary")
+	}
+
+	var summary strings.Builder
+	for _, part := range geminiResponse.Candidates[0].Content.Parts {
+		summary.WriteString(part.Text)
+	}
+	return strings.TrimSpace(summary.String()), nil
+}
+
+func (p *GeminiProvider) post(ctx context.Context, method, params string, payload geminiRequest) (*http.Response, error) {
+	url := strings.TrimRight(p.BaseURL, "/") + "/models/" + p.Model + ":" + method + "?" + params + "key=" + p.APIKey
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("gemini %s: %s", resp.Status, body)
+	}
+
+	return resp, nil
+}
+
+func buildGeminiRequest(prompt model.Prompt) geminiRequest {
+	system := geminiPersona + "\n\n"
+	if prompt.TableSummary != "" {
+		system += prompt.TableSummary + "\n\n"
+	}
+	system += tableToCSV(prompt.Table)
+	for _, note := range prompt.Notes {
+		system += "\n\n" + note
+	}
+	if prompt.Summary != "" {
+		system += "\n\nRingkasan percakapan sebelumnya:\n" + prompt.Summary
+	}
+
+	contents := make([]geminiContent, 0, len(prompt.History)+1)
+	for _, message := range prompt.History {
+		parts := make([]model.Part, 0, len(message.Parts))
+		for _, part := range message.Parts {
+			if strings.TrimSpace(part.Text) != "" {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		role := geminiRole(message.Role)
+		if n := len(contents); n > 0 && contents[n-1].Role == role {
+			contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+			continue
+		}
+		contents = append(contents, geminiContent{Role: role, Parts: parts})
+	}
+
+	if n := len(contents); n > 0 && contents[n-1].Role == "user" {
+		contents[n-1].Parts = append(contents[n-1].Parts, model.Part{Text: prompt.Query})
+	} else {
+		contents = append(contents, geminiContent{Role: "user", Parts: []model.Part{{Text: prompt.Query}}})
+	}
+
+	return geminiRequest{
+		SystemInstruction: &geminiContent{Parts: []model.Part{{Text: system}}},
+		Contents:          contents,
+	}
+}
+
+func geminiRole(role string) string {
+	if role == "assistant" || role == "model" {
+		return "model"
+	}
+	return "user"
+}
+
+func tableToCSV(table map[string][]string) string {
+	columns := make([]string, 0, len(table))
+	rows := 0
+	for column, values := range table {
+		columns = append(columns, column)
+		if len(values) > rows {
+			rows = len(values)
+		}
+	}
+	sort.Strings(columns)
+
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write(columns)
+	for i := 0; i < rows; i++ {
+		record := make([]string, len(columns))
+		for j, column := range columns {
+			if i < len(table[column]) {
+				record[j] = table[column][i]
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"luma-backend/model"
+)
+
+func TestStreamRecommendCancelsUpstream(t *testing.T) {
+	upstreamDone := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(upstreamDone)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"candidates":[{"content":{"parts":[{"text":"Matikan "}]}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	provider := &GeminiProvider{Client: server.Client(), BaseURL: server.URL, Model: "test", APIKey: "key"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	returned := make(chan model.APIResponse)
+	go func() {
+		response, _ := provider.StreamRecommend(ctx, model.Prompt{Query: "hemat?"}, func(chunk string) error {
+			cancel()
+			return nil
+		})
+		returned <- response
+	}()
+
+	select {
+	case response := <-returned:
+		if text := response.Candidates[0].Content.Parts[0].Text; text != "Matikan " {
+			t.Errorf("assembled text = %q, want the chunk sent before cancelling", text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("StreamRecommend did not return after its context was cancelled")
+	}
+
+	select {
+	case <-upstreamDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream request was not cancelled")
+	}
+}
+
+func TestBuildGeminiRequestAsksQueryOnce(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []model.Message
+		want    []geminiContent
+	}{
+		{
+			name: "no history",
+			want: []geminiContent{{Role: "user", Parts: []model.Part{{Text: "hemat?"}}}},
+		},
+		{
+			name: "earlier turn",
+			history: []model.Message{
+				{Role: "user", Parts: []model.Part{{Text: "TV?"}}},
+				{Role: "assistant", Parts: []model.Part{{Text: "0.8"}}},
+				{Role: "assistant", Parts: []model.Part{{Text: "Matikan TV."}}},
+			},
+			want: []geminiContent{
+				{Role: "user", Parts: []model.Part{{Text: "TV?"}}},
+				{Role: "model", Parts: []model.Part{{Text: "0.8"}, {Text: "Matikan TV."}}},
+				{Role: "user", Parts: []model.Part{{Text: "hemat?"}}},
+			},
+		},
+		{
+			name: "unanswered question",
+			history: []model.Message{
+				{Role: "user", Parts: []model.Part{{Text: "TV?"}}},
+			},
+			want: []geminiContent{
+				{Role: "user", Parts: []model.Part{{Text: "TV?"}, {Text: "hemat?"}}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := buildGeminiRequest(model.Prompt{Query: "hemat?", History: test.history, Notes: []string{"Jawaban model tabel untuk pertanyaan ini: 2.4"}})
+			if !reflect.DeepEqual(request.Contents, test.want) {
+				t.Errorf("contents = %+v, want %+v", request.Contents, test.want)
+			}
+			if !strings.Contains(request.SystemInstruction.Parts[0].Text, "2.4") {
+				t.Error("system instruction is missing the prompt notes")
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"luma-backend/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type HouseholdRepository struct {
+	Client *mongo.Client
+	DB     *mongo.Database
+}
+
+func NewHouseholdRepository(client *mongo.Client, dbName string) *HouseholdRepository {
+	db := client.Database(dbName)
+	return &HouseholdRepository{
+		Client: client,
+		DB:     db,
+	}
+}
+
+func (r *HouseholdRepository) CreateHousehold(household model.Household) error {
+	collection := r.DB.Collection("households")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, household)
+	return err
+}
+
+func (r *HouseholdRepository) GetHousehold(householdID string) (*model.Household, error) {
+	return r.findOne(bson.M{"household_id": householdID})
+}
+
+// FindHouseholdByMember returns the household email has joined, or nil when
+// the user only has pending invitations or none at all.
+func (r *HouseholdRepository) FindHouseholdByMember(email string) (*model.Household, error) {
+	return r.findOne(bson.M{"members": bson.M{"$elemMatch": bson.M{"email": email, "status": model.MemberActive}}})
+}
+
+func (r *HouseholdRepository) ListInvitations(email string) ([]model.Household, error) {
+	return r.find(bson.M{"members": bson.M{"$elemMatch": bson.M{"email": email, "status": model.MemberInvited}}})
+}
+
+// ListHouseholdsByMember returns every household email is active in. Outside
+// a race between two acceptances there is at most one.
+func (r *HouseholdRepository) ListHouseholdsByMember(email string) ([]model.Household, error) {
+	return r.find(bson.M{"members": bson.M{"$elemMatch": bson.M{"email": email, "status": model.MemberActive}}})
+}
+
+// AddMember appends a member unless the email is already listed, in which
+// case it reports false.
+func (r *HouseholdRepository) AddMember(householdID string, member model.HouseholdMember) (bool, error) {
+	collection := r.DB.Collection("households")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"household_id": householdID, "members.email": bson.M{"$ne": member.Email}}
+	update := bson.M{"$push": bson.M{"members": member}}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *HouseholdRepository) UpdateMemberRole(householdID, email string, role model.HouseholdRole) (bool, error) {
+	collection := r.DB.Collection("households")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"household_id": householdID, "members.email": email}
+	update := bson.M{"$set": bson.M{"members.$.role": role}}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// AcceptInvitation claims a pending invitation in a single conditional
+// update and returns the household as it is afterwards, or nil when there is
+// no pending invitation for email, including one that was just claimed.
+func (r *HouseholdRepository) AcceptInvitation(householdID, email string) (*model.Household, error) {
+	collection := r.DB.Collection("households")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"household_id": householdID,
+		"members":      bson.M{"$elemMatch": bson.M{"email": email, "status": model.MemberInvited}},
+	}
+	update := bson.M{"$set": bson.M{"members.$.status": model.MemberActive, "members.$.joined_at": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var household model.Household
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&household)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &household, nil
+}
+
+// ReopenInvitation turns an accepted membership back into a pending
+// invitation.
+func (r *HouseholdRepository) ReopenInvitation(householdID, email string) error {
+	collection := r.DB.Collection("households")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"household_id": householdID,
+		"members":      bson.M{"$elemMatch": bson.M{"email": email, "status": model.MemberActive}},
+	}
+	update := bson.M{
+		"$set":   bson.M{"members.$.status": model.MemberInvited},
+		"$unset": bson.M{"members.$.joined_at": ""},
+	}
+
+	_, err := collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *HouseholdRepository) RemoveMember(householdID, email string) (bool, error) {
+	collection := r.DB.Collection("households")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"household_id": householdID, "members.email": email}
+	update := bson.M{"$pull": bson.M{"members": bson.M{"email": email}}}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *HouseholdRepository) EnsureIndexes() error {
+	collection := r.DB.Collection("households")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "household_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "members.email", Value: 1}}},
+	})
+	return err
+}
+
+func (r *HouseholdRepository) findOne(filter bson.M) (*model.Household, error) {
+	collection := r.DB.Collection("households")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var household model.Household
+	err := collection.FindOne(ctx, filter).Decode(&household)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &household, nil
+}
+
+func (r *HouseholdRepository) find(filter bson.M) ([]model.Household, error) {
+	collection := r.DB.Collection("households")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	households := make([]model.Household, 0)
+	err = cursor.All(ctx, &households)
+	return households, err
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"luma-backend/model"
+)
+
+const (
+	DefaultHuggingFaceBaseURL = "https://api-inference.huggingface.co/models"
+	DefaultTableQAModel       = "google/tapas-base-finetuned-wtq"
+)
+
+type HuggingFaceProvider struct {
+	Client  *http.Client
+	BaseURL string
+	Model   string
+	Token   string
+}
+
+func (p *HuggingFaceProvider) ModelName() string {
+	return p.Model
+}
+
+func (p *HuggingFaceProvider) AnswerTable(inputs model.Inputs) (model.Response, error) {
+	url := strings.TrimRight(p.BaseURL, "/") + "/" + p.Model
+	jsonPayload, err := json.Marshal(inputs)
+	if err != nil {
+		return model.Response{}, err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return model.Response{}, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+p.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return model.Response{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return model.Response{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return model.Response{}, fmt.Errorf("huggingface %s: %s", resp.Status, body)
+	}
+
+	var response model.Response
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return model.Response{}, err
+	}
+
+	return response, nil
+}
@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"luma-backend/model"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+	"golang.org/x/oauth2/google"
+	googleoauth "google.golang.org/api/oauth2/v2"
+	"google.golang.org/api/option"
+)
+
+// IdentityProvider is an OAuth 2.0 login provider that can report the
+// signed-in user's profile once the authorization code has been exchanged.
+type IdentityProvider interface {
+	Name() string
+	OAuthConfig() *oauth2.Config
+	Profile(ctx context.Context, token *oauth2.Token) (model.ExternalProfile, error)
+}
+
+type GoogleIdentityProvider struct {
+	Config *oauth2.Config
+}
+
+func NewGoogleIdentityProvider(clientID, clientSecret, redirectURL string) *GoogleIdentityProvider {
+	return &GoogleIdentityProvider{Config: &oauth2.Config{
+		RedirectURL:  redirectURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/userinfo.profile"},
+		Endpoint:     google.Endpoint,
+	}}
+}
+
+func (p *GoogleIdentityProvider) Name() string {
+	return "google"
+}
+
+func (p *GoogleIdentityProvider) OAuthConfig() *oauth2.Config {
+	return p.Config
+}
+
+func (p *GoogleIdentityProvider) Profile(ctx context.Context, token *oauth2.Token) (model.ExternalProfile, error) {
+	service, err := googleoauth.NewService(ctx, option.WithHTTPClient(p.Config.Client(ctx, token)))
+	if err != nil {
+		return model.ExternalProfile{}, err
+	}
+
+	userinfo, err := service.Userinfo.Get().Do()
+	if err != nil {
+		return model.ExternalProfile{}, err
+	}
+
+	return model.ExternalProfile{
+		Provider:      p.Name(),
+		Subject:       userinfo.Id,
+		Email:         userinfo.Email,
+		EmailVerified: userinfo.VerifiedEmail != nil && *userinfo.VerifiedEmail,
+		Name:          userinfo.Name,
+		Picture:       userinfo.Picture,
+	}, nil
+}
+
+type GitHubIdentityProvider struct {
+	Config  *oauth2.Config
+	BaseURL string
+}
+
+func NewGitHubIdentityProvider(clientID, clientSecret, redirectURL string) *GitHubIdentityProvider {
+	return &GitHubIdentityProvider{
+		Config: &oauth2.Config{
+			RedirectURL:  redirectURL,
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Scopes:       []string{"read:user", "user:email"},
+			Endpoint:     github.Endpoint,
+		},
+		BaseURL: "https://api.github.com",
+	}
+}
+
+func (p *GitHubIdentityProvider) Name() string {
+	return "github"
+}
+
+func (p *GitHubIdentityProvider) OAuthConfig() *oauth2.Config {
+	return p.Config
+}
+
+// Profile reads the account and its primary email. The email on /user is
+// whatever the user chose to make public, so verification comes from
+// /user/emails instead.
+func (p *GitHubIdentityProvider) Profile(ctx context.Context, token *oauth2.Token) (model.ExternalProfile, error) {
+	client := p.Config.Client(ctx, token)
+
+	var user struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	err := getJSON(ctx, client, p.BaseURL+"/user", &user)
+	if err != nil {
+		return model.ExternalProfile{}, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	err = getJSON(ctx, client, p.BaseURL+"/user/emails", &emails)
+	if err != nil {
+		return model.ExternalProfile{}, err
+	}
+
+	profile := model.ExternalProfile{
+		Provider: p.Name(),
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Name,
+		Picture:  user.AvatarURL,
+	}
+	if profile.Name == "" {
+		profile.Name = user.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			profile.Email = email.Email
+			profile.EmailVerified = email.Verified
+		}
+	}
+	return profile, nil
+}
+
+// OIDCIdentityProvider signs users in with any OpenID Connect issuer, using
+// the endpoints published in its discovery document.
+type OIDCIdentityProvider struct {
+	ProviderName string
+	Config       *oauth2.Config
+	UserInfoURL  string
+}
+
+func NewOIDCIdentityProvider(ctx context.Context, client *http.Client, name, issuer, clientID, clientSecret, redirectURL string) (*OIDCIdentityProvider, error) {
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	issuer = strings.TrimRight(issuer, "/")
+	err := getJSON(ctx, client, issuer+"/.well-known/openid-configuration", &discovery)
+	if err != nil {
+		return nil, fmt.Errorf("OIDC discovery for %s: %w", issuer, err)
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OIDC discovery for %s returned issuer %q", issuer, discovery.Issuer)
+	}
+	if discovery.UserInfoEndpoint == "" {
+		return nil, fmt.Errorf("OIDC issuer %s does not publish a userinfo endpoint", issuer)
+	}
+
+	return &OIDCIdentityProvider{
+		ProviderName: name,
+		Config: &oauth2.Config{
+			RedirectURL:  redirectURL,
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Scopes:       []string{"openid", "email", "profile"},
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  discovery.AuthorizationEndpoint,
+				TokenURL: discovery.TokenEndpoint,
+			},
+		},
+		UserInfoURL: discovery.UserInfoEndpoint,
+	}, nil
+}
+
+func (p *OIDCIdentityProvider) Name() string {
+	return p.ProviderName
+}
+
+func (p *OIDCIdentityProvider) OAuthConfig() *oauth2.Config {
+	return p.Config
+}
+
+func (p *OIDCIdentityProvider) Profile(ctx context.Context, token *oauth2.Token) (model.ExternalProfile, error) {
+	var claims struct {
+		Subject       string          `json:"sub"`
+		Email         string          `json:"email"`
+		EmailVerified json.RawMessage `json:"email_verified"`
+		Name          string          `json:"name"`
+		Picture       string          `json:"picture"`
+	}
+	err := getJSON(ctx, p.Config.Client(ctx, token), p.UserInfoURL, &claims)
+	if err != nil {
+		return model.ExternalProfile{}, err
+	}
+
+	// Some issuers send email_verified as the string "true".
+	verified := strings.Trim(string(claims.EmailVerified), `"`) == "true"
+
+	return model.ExternalProfile{
+		Provider:      p.Name(),
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: verified,
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("GET %s: %s: %s", url, resp.Status, body)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
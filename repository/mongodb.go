@@ -58,17 +58,106 @@ func (r *MongoRepository) FindUserByEmail(email string) (*model.User, error) {
 	return &user, nil
 }
 
-func (r *MongoRepository) SaveSession(sessionID string, user model.User) error {
-	collection := r.DB.Collection("sessions")
+// LinkIdentity records an external login on the user's account unless that
+// provider account is already linked.
+func (r *MongoRepository) LinkIdentity(email string, identity model.Identity) error {
+	collection := r.DB.Collection("users")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	sessionData := bson.M{
-		"session_id": sessionID,
-		"user":       user,
-		"created_at": time.Now(),
+	filter := bson.M{
+		"email": email,
+		"identities": bson.M{"$not": bson.M{"$elemMatch": bson.M{
+			"provider": identity.Provider,
+			"subject":  identity.Subject,
+		}}},
 	}
+	update := bson.M{"$push": bson.M{"identities": identity}}
+
+	_, err := collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *MongoRepository) SaveSession(session model.Session) error {
+	collection := r.DB.Collection("sessions")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	_, err := collection.InsertOne(ctx, sessionData)
+	_, err := collection.InsertOne(ctx, session)
 	return err
 }
+
+func (r *MongoRepository) FindSession(sessionID string) (*model.Session, error) {
+	collection := r.DB.Collection("sessions")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var session model.Session
+	err := collection.FindOne(ctx, bson.M{"session_id": sessionID}).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *MongoRepository) ListActiveSessions(email string) ([]model.Session, error) {
+	collection := r.DB.Collection("sessions")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"user.email": email,
+		"revoked_at": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": time.Now()}},
+		},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := make([]model.Session, 0)
+	err = cursor.All(ctx, &sessions)
+	return sessions, err
+}
+
+func (r *MongoRepository) RevokeSession(email, sessionID string) (bool, error) {
+	collection := r.DB.Collection("sessions")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"session_id": sessionID, "user.email": email}
+	update := bson.M{"$set": bson.M{"revoked_at": time.Now()}}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *MongoRepository) FindSessionOwner(sessionID string) (string, error) {
+	collection := r.DB.Collection("sessions")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var session struct {
+		User model.User `bson:"user"`
+	}
+	err := collection.FindOne(ctx, bson.M{"session_id": sessionID}).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "", nil
+		}
+		return "", err
+	}
+	return session.User.Email, nil
+}
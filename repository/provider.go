@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+
+	"luma-backend/model"
+)
+
+type TableQAProvider interface {
+	ModelName() string
+	AnswerTable(inputs model.Inputs) (model.Response, error)
+}
+
+type RecommendationProvider interface {
+	ModelName() string
+	Recommend(prompt model.Prompt) (model.APIResponse, error)
+}
+
+// StreamRecommend returns the response assembled from every streamed chunk,
+// including the usage metadata sent with the last one. Cancelling ctx stops
+// the upstream stream.
+type StreamingRecommendationProvider interface {
+	RecommendationProvider
+	StreamRecommend(ctx context.Context, prompt model.Prompt, onChunk func(string) error) (model.APIResponse, error)
+}
+
+type Summarizer interface {
+	Summarize(previous string, messages []model.Message) (string, error)
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"luma-backend/model"
+)
+
+// A column keeps its inferred type as long as at least this share of its
+// non-empty cells parse, so a handful of typos surface as row errors instead
+// of silently demoting the whole column to categorical.
+const schemaInferenceThreshold = 0.9
+
+var (
+	dateLayouts = []string{"2006-01-02", "2006/01/02", "02/01/2006", "02-01-2006"}
+	timeLayouts = []string{"15:04", "15:04:05", "3:04 PM", "3:04PM"}
+)
+
+// InferSchema types every column of table. decimalComma marks the table as
+// locale-formatted, so "1,2" in a numeric column reads as 1.2; it should only
+// be set for semicolon-delimited files, where a comma cannot be a delimiter.
+func InferSchema(table map[string][]string, decimalComma bool) model.Schema {
+	names := make([]string, 0, len(table))
+	for name := range table {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	schema := model.Schema{Columns: make([]model.Column, 0, len(names))}
+	for _, name := range names {
+		column := model.Column{Name: name, Type: inferColumnType(table[name], decimalComma)}
+		column.DecimalComma = decimalComma && column.Type == model.ColumnFloat
+		schema.Columns = append(schema.Columns, column)
+	}
+	return schema
+}
+
+func inferColumnType(values []string, decimalComma bool) model.ColumnType {
+	candidates := []model.ColumnType{model.ColumnDate, model.ColumnTime, model.ColumnInt, model.ColumnFloat}
+	counts := make(map[model.ColumnType]int)
+	total := 0
+
+	for _, raw := range values {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		total++
+		for _, columnType := range candidates {
+			if _, err := ParseValue(model.Column{Type: columnType, DecimalComma: decimalComma}, raw); err == nil {
+				counts[columnType]++
+			}
+		}
+	}
+
+	if total == 0 {
+		return model.ColumnCategorical
+	}
+
+	passes := func(columnType model.ColumnType) bool {
+		return float64(counts[columnType])/float64(total) >= schemaInferenceThreshold
+	}
+
+	switch {
+	case passes(model.ColumnDate):
+		return model.ColumnDate
+	case passes(model.ColumnTime):
+		return model.ColumnTime
+	case passes(model.ColumnInt) && counts[model.ColumnInt] == counts[model.ColumnFloat]:
+		return model.ColumnInt
+	case passes(model.ColumnFloat):
+		return model.ColumnFloat
+	default:
+		return model.ColumnCategorical
+	}
+}
+
+func ParseValue(column model.Column, raw string) (model.Value, error) {
+	raw = strings.TrimSpace(raw)
+	value := model.Value{Raw: raw}
+
+	switch column.Type {
+	case model.ColumnDate:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, raw); err == nil {
+				value.Time = t
+				value.Valid = true
+				return value, nil
+			}
+		}
+		return value, fmt.Errorf("%q is not a valid date", raw)
+	case model.ColumnTime:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, raw); err == nil {
+				value.Time = t
+				value.Valid = true
+				return value, nil
+			}
+		}
+		return value, fmt.Errorf("%q is not a valid time", raw)
+	case model.ColumnInt:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return value, fmt.Errorf("%q is not a valid integer", raw)
+		}
+		value.Number = float64(n)
+		value.Valid = true
+		return value, nil
+	case model.ColumnFloat:
+		number := raw
+		if column.DecimalComma {
+			number = normalizeDecimal(raw)
+		}
+		n, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return value, fmt.Errorf("%q is not a valid number", raw)
+		}
+		value.Number = n
+		value.Valid = true
+		return value, nil
+	default:
+		value.Valid = raw != ""
+		return value, nil
+	}
+}
+
+func TypeTable(table map[string][]string, schema model.Schema) (model.TypedTable, []model.RowError) {
+	rowCount := 0
+	for _, column := range schema.Columns {
+		if n := len(table[column.Name]); n > rowCount {
+			rowCount = n
+		}
+	}
+
+	typed := model.TypedTable{Schema: schema, Rows: make([][]model.Value, rowCount)}
+	var rowErrors []model.RowError
+
+	for i := 0; i < rowCount; i++ {
+		row := make([]model.Value, len(schema.Columns))
+		for j, column := range schema.Columns {
+			values := table[column.Name]
+			if i >= len(values) || strings.TrimSpace(values[i]) == "" {
+				row[j] = model.Value{}
+				continue
+			}
+
+			value, err := ParseValue(column, values[i])
+			if err != nil {
+				rowErrors = append(rowErrors, model.RowError{
+					Line:   i + 2,
+					Column: column.Name,
+					Reason: err.Error(),
+				})
+			}
+			row[j] = value
+		}
+		typed.Rows[i] = row
+	}
+
+	return typed, rowErrors
+}
+
+// Semicolon-delimited exports from Indonesian Excel locales write decimals
+// with a comma ("1,2"). Elsewhere a lone comma is just as likely to group
+// thousands ("1,234"), so this only runs for columns inferred from such files.
+func normalizeDecimal(raw string) string {
+	if strings.Count(raw, ",") == 1 && !strings.Contains(raw, ".") {
+		return strings.Replace(raw, ",", ".", 1)
+	}
+	return raw
+}
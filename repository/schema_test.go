@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"testing"
+
+	"luma-backend/model"
+)
+
+func TestDecimalCommaOnlyForSemicolonFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want float64
+		ok   bool
+	}{
+		{"semicolon decimal comma", "Appliance;Energy_Consumption\nTV;1,2\nHeater;0,5\n", 1.2, true},
+		{"semicolon decimal dot", "Appliance;Energy_Consumption\nTV;1.2\nHeater;0.5\n", 1.2, true},
+		{"comma file decimal dot", "Appliance,Energy_Consumption\nTV,1.2\nHeater,0.5\n", 1.2, true},
+		{"quoted thousands in comma file", "Appliance,Energy_Consumption\nTV,\"1,234\"\nHeater,0.5\n", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			table, report, err := ParseCSV(test.data)
+			if err != nil {
+				t.Fatalf("ParseCSV: %v", err)
+			}
+			schema := InferSchema(table, report.Delimiter == ";")
+			typed, _ := TypeTable(table, schema)
+
+			index := schema.ColumnIndex("Energy_Consumption")
+			value := typed.Rows[0][index]
+			if schema.Columns[index].Type != model.ColumnFloat {
+				if test.ok {
+					t.Fatalf("column type = %s, want float", schema.Columns[index].Type)
+				}
+				return
+			}
+			if value.Valid != test.ok {
+				t.Fatalf("valid = %v, want %v", value.Valid, test.ok)
+			}
+			if test.ok && value.Number != test.want {
+				t.Errorf("number = %v, want %v", value.Number, test.want)
+			}
+		})
+	}
+}
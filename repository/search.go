@@ -0,0 +1,349 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"luma-backend/model"
+)
+
+// searchCandidate is a message from a conversation the text index matched.
+// Position is its index in the conversation.
+type searchCandidate struct {
+	SessionID string        `bson:"session_id"`
+	Message   model.Message `bson:"messages"`
+	Position  int           `bson:"position"`
+}
+
+// rankMessages scores each candidate on its own against query and returns
+// the best limit matches, newest first among equal scores. Messages with no
+// query term score 0 and are left out.
+func rankMessages(candidates []searchCandidate, query string, limit int) []model.SearchResult {
+	terms := queryTerms(query)
+	type match struct {
+		searchCandidate
+		score float64
+	}
+	matches := make([]match, 0)
+	for _, candidate := range candidates {
+		if score := messageScore(partsText(candidate.Message.Parts), terms); score > 0 {
+			matches = append(matches, match{candidate, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if !a.Message.CreatedAt.Equal(b.Message.CreatedAt) {
+			return a.Message.CreatedAt.After(b.Message.CreatedAt)
+		}
+		return a.Position > b.Position
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	results := make([]model.SearchResult, 0, len(matches))
+	for _, m := range matches {
+		results = append(results, model.SearchResult{ConversationID: m.SessionID, Message: m.Message, Score: m.score})
+	}
+	return results
+}
+
+// queryTerms tokenises a $text search string. Negated words ("-tv") only
+// exclude conversations in the index and are not scored.
+func queryTerms(query string) []string {
+	var terms []string
+	for _, field := range strings.Fields(query) {
+		if strings.HasPrefix(field, "-") {
+			continue
+		}
+		terms = append(terms, searchTokens(field)...)
+	}
+	return terms
+}
+
+// messageScore rates text against the query terms much as MongoDB's textScore
+// rates a document: each distinct term present adds 0.5 plus half its share
+// of the text's terms.
+func messageScore(text string, terms []string) float64 {
+	tokens := searchTokens(text)
+	if len(tokens) == 0 {
+		return 0
+	}
+	counts := make(map[string]int, len(tokens))
+	for _, token := range tokens {
+		counts[token]++
+	}
+
+	score := 0.0
+	for _, term := range terms {
+		if count := counts[term]; count > 0 {
+			score += 0.5 + 0.5*float64(count)/float64(len(tokens))
+			delete(counts, term)
+		}
+	}
+	return score
+}
+
+// searchTokens splits text the way the chat_history text index does for its
+// default English language: lowercase words, stop words dropped, the rest
+// reduced with the Snowball English stemmer.
+func searchTokens(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := words[:0]
+	for _, word := range words {
+		if !englishStopWords[word] {
+			tokens = append(tokens, stemEnglish(word))
+		}
+	}
+	return tokens
+}
+
+var englishStopWords = func() map[string]bool {
+	words := strings.Fields(`a about above after again against all am an and any are as at be because
+		been before being below between both but by can cannot could did do does doing down during each
+		few for from further had has have having he her here hers herself him himself his how i if in
+		into is it its itself just me more most my myself no nor not now of off on once only or other
+		ought our ours ourselves out over own same she should so some such than that the their theirs
+		them themselves then there these they this those through to too under until up very was we were
+		what when where which while who whom why will with would you your yours yourself yourselves`)
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}()
+
+var englishStemExceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli", "singly": "singl",
+	"sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas", "cosmos": "cosmos", "bias": "bias", "andes": "andes",
+}
+
+var englishStep1aExceptions = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true,
+	"earring": true, "proceed": true, "exceed": true, "succeed": true,
+}
+
+type stemRule struct {
+	suffix, replacement string
+}
+
+// Suffixes are listed longest first; only the longest one present applies.
+var (
+	englishStep2 = []stemRule{
+		{"ization", "ize"}, {"ational", "ate"}, {"fulness", "ful"}, {"ousness", "ous"}, {"iveness", "ive"},
+		{"tional", "tion"}, {"biliti", "ble"}, {"lessli", "less"},
+		{"entli", "ent"}, {"ation", "ate"}, {"alism", "al"}, {"aliti", "al"}, {"ousli", "ous"}, {"iviti", "ive"}, {"fulli", "ful"},
+		{"enci", "ence"}, {"anci", "ance"}, {"abli", "able"}, {"izer", "ize"}, {"ator", "ate"}, {"alli", "al"},
+		{"bli", "ble"}, {"ogi", "og"},
+		{"li", ""},
+	}
+	englishStep3 = []stemRule{
+		{"ational", "ate"},
+		{"tional", "tion"},
+		{"alize", "al"}, {"icate", "ic"}, {"iciti", "ic"}, {"ative", ""},
+		{"ical", "ic"}, {"ness", ""},
+		{"ful", ""},
+	}
+	englishStep4 = []string{
+		"ement",
+		"ance", "ence", "able", "ible", "ment",
+		"ant", "ent", "ism", "ate", "iti", "ous", "ive", "ize", "ion",
+		"al", "er", "ic",
+	}
+)
+
+// stemEnglish is the Snowball (Porter2) English stemmer, which MongoDB uses
+// for English text indexes. Words that are not plain ASCII are kept as they are.
+func stemEnglish(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+	if stem, ok := englishStemExceptions[word]; ok {
+		return stem
+	}
+
+	w := []byte(word)
+	if w[0] == 'y' {
+		w[0] = 'Y'
+	}
+	for i := 1; i < len(w); i++ {
+		if w[i] == 'y' && isStemVowel(w[i-1]) {
+			w[i] = 'Y'
+		}
+	}
+
+	r1 := stemRegion(w, 0)
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(word, prefix) {
+			r1 = len(prefix)
+		}
+	}
+	r2 := stemRegion(w, r1)
+
+	// Step 1a: plurals.
+	switch {
+	case hasStemSuffix(w, "sses"):
+		w = w[:len(w)-2]
+	case hasStemSuffix(w, "ied"), hasStemSuffix(w, "ies"):
+		if len(w) > 4 {
+			w = w[:len(w)-2]
+		} else {
+			w = w[:len(w)-1]
+		}
+	case hasStemSuffix(w, "us"), hasStemSuffix(w, "ss"):
+	case hasStemSuffix(w, "s"):
+		if containsStemVowel(w[:len(w)-2]) {
+			w = w[:len(w)-1]
+		}
+	}
+	if englishStep1aExceptions[string(w)] {
+		return string(w)
+	}
+
+	// Step 1b: past tenses and gerunds.
+	switch {
+	case hasStemSuffix(w, "eedly"):
+		if len(w)-5 >= r1 {
+			w = w[:len(w)-3]
+		}
+	case hasStemSuffix(w, "eed"):
+		if len(w)-3 >= r1 {
+			w = w[:len(w)-1]
+		}
+	default:
+		for _, suffix := range []string{"ingly", "edly", "ing", "ed"} {
+			if !hasStemSuffix(w, suffix) {
+				continue
+			}
+			if stem := w[:len(w)-len(suffix)]; containsStemVowel(stem) {
+				w = stem
+				switch {
+				case hasStemSuffix(w, "at"), hasStemSuffix(w, "bl"), hasStemSuffix(w, "iz"):
+					w = append(w, 'e')
+				case endsWithStemDouble(w):
+					w = w[:len(w)-1]
+				case r1 >= len(w) && endsWithShortSyllable(w):
+					w = append(w, 'e')
+				}
+			}
+			break
+		}
+	}
+
+	// Step 1c: a final y after a consonant.
+	if n := len(w); n > 2 && (w[n-1] == 'y' || w[n-1] == 'Y') && !isStemVowel(w[n-2]) {
+		w[n-1] = 'i'
+	}
+
+	// Step 2.
+	for _, rule := range englishStep2 {
+		if !hasStemSuffix(w, rule.suffix) {
+			continue
+		}
+		start := len(w) - len(rule.suffix)
+		if start >= r1 {
+			switch rule.suffix {
+			case "ogi":
+				if start > 0 && w[start-1] == 'l' {
+					w = append(w[:start], rule.replacement...)
+				}
+			case "li":
+				if start > 0 && strings.IndexByte("cdeghkmnrt", w[start-1]) >= 0 {
+					w = w[:start]
+				}
+			default:
+				w = append(w[:start], rule.replacement...)
+			}
+		}
+		break
+	}
+
+	// Step 3.
+	for _, rule := range englishStep3 {
+		if !hasStemSuffix(w, rule.suffix) {
+			continue
+		}
+		start := len(w) - len(rule.suffix)
+		if start >= r1 && (rule.suffix != "ative" || start >= r2) {
+			w = append(w[:start], rule.replacement...)
+		}
+		break
+	}
+
+	// Step 4.
+	for _, suffix := range englishStep4 {
+		if !hasStemSuffix(w, suffix) {
+			continue
+		}
+		start := len(w) - len(suffix)
+		if start >= r2 && (suffix != "ion" || (start > 0 && (w[start-1] == 's' || w[start-1] == 't'))) {
+			w = w[:start]
+		}
+		break
+	}
+
+	// Step 5.
+	if n := len(w); n > 0 && w[n-1] == 'e' {
+		if n-1 >= r2 || (n-1 >= r1 && !endsWithShortSyllable(w[:n-1])) {
+			w = w[:n-1]
+		}
+	} else if n > 1 && w[n-1] == 'l' && n-1 >= r2 && w[n-2] == 'l' {
+		w = w[:n-1]
+	}
+
+	return strings.ToLower(string(w))
+}
+
+func isStemVowel(c byte) bool {
+	return strings.IndexByte("aeiouy", c) >= 0
+}
+
+func containsStemVowel(w []byte) bool {
+	for _, c := range w {
+		if isStemVowel(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// stemRegion returns where the region after the first non-vowel following
+// a vowel at or after from begins: R1 from 0, R2 from R1.
+func stemRegion(w []byte, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if isStemVowel(w[i-1]) && !isStemVowel(w[i]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+func hasStemSuffix(w []byte, suffix string) bool {
+	return len(w) >= len(suffix) && string(w[len(w)-len(suffix):]) == suffix
+}
+
+func endsWithStemDouble(w []byte) bool {
+	n := len(w)
+	return n >= 2 && w[n-1] == w[n-2] && strings.IndexByte("bdfgmnprt", w[n-1]) >= 0
+}
+
+func endsWithShortSyllable(w []byte) bool {
+	n := len(w)
+	if n == 2 {
+		return isStemVowel(w[0]) && !isStemVowel(w[1])
+	}
+	return n >= 3 && !isStemVowel(w[n-3]) && isStemVowel(w[n-2]) && !isStemVowel(w[n-1]) &&
+		w[n-1] != 'w' && w[n-1] != 'x' && w[n-1] != 'Y'
+}
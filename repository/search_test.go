@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"testing"
+
+	"luma-backend/model"
+)
+
+func TestStemEnglish(t *testing.T) {
+	tests := map[string]string{
+		"consign": "consign", "consigned": "consign", "consigning": "consign", "consignment": "consign",
+		"consist": "consist", "consisted": "consist", "consistency": "consist", "consistent": "consist",
+		"consistently": "consist", "consisting": "consist", "consists": "consist",
+		"knack": "knack", "knackeries": "knackeri", "knocked": "knock", "knocker": "knocker", "knocks": "knock",
+		"generously": "generous", "caresses": "caress", "ponies": "poni", "ties": "tie", "cries": "cri",
+		"gas": "gas", "gaps": "gap", "hopping": "hop", "hoped": "hope", "running": "run", "happy": "happi",
+		"devices": "devic", "device": "devic", "skies": "sky", "succeeding": "succeed",
+		"menggunakan": "menggunakan", "kulkas": "kulka",
+	}
+
+	for word, want := range tests {
+		if got := stemEnglish(word); got != want {
+			t.Errorf("stemEnglish(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestSearchMessagesScoresEachMessage(t *testing.T) {
+	chats := NewMemoryChatRepository()
+	save := func(sessionID, text string) {
+		chats.SaveMessage(sessionID, model.Message{Role: "user", Parts: []model.Part{{Text: text}}})
+	}
+	save("a", "Which devices use the most energy?")
+	save("a", "Turn off the heater at night.")
+	save("a", "The device list is long.")
+	save("b", "Heater heater heater")
+	save("b", "Nothing to see here")
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"stemmed forms match", "device", []string{"The device list is long.", "Which devices use the most energy?"}},
+		{"term density ranks first", "heater", []string{"Heater heater heater", "Turn off the heater at night."}},
+		{"stop words alone match nothing", "the", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results, err := chats.SearchMessages([]string{"a", "b"}, test.query, 10)
+			if err != nil {
+				t.Fatalf("SearchMessages: %v", err)
+			}
+			if len(results) != len(test.want) {
+				t.Fatalf("got %d results, want %d: %+v", len(results), len(test.want), results)
+			}
+			for i, result := range results {
+				if text := partsText(result.Message.Parts); text != test.want[i] {
+					t.Errorf("result %d = %q, want %q", i, text, test.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"luma-backend/model"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Tariffs live next to the dataset they price and are keyed the same way:
+// per household, or per user outside a household.
+
+func (r *DatasetRepository) SaveTariff(tariff model.Tariff) error {
+	collection := r.DB.Collection("tariffs")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := datasetFilter(tariff.Email, tariff.HouseholdID)
+	opts := options.Replace().SetUpsert(true)
+
+	_, err := collection.ReplaceOne(ctx, filter, tariff, opts)
+	return err
+}
+
+func (r *DatasetRepository) GetTariff(email, householdID string) (*model.Tariff, error) {
+	collection := r.DB.Collection("tariffs")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var tariff model.Tariff
+	err := collection.FindOne(ctx, datasetFilter(email, householdID)).Decode(&tariff)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &tariff, nil
+}
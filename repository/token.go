@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"luma-backend/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (r *MongoRepository) SaveRefreshToken(token model.RefreshToken) error {
+	collection := r.DB.Collection("refresh_tokens")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, token)
+	return err
+}
+
+// ConsumeRefreshToken atomically marks an unused, unrevoked token as used and
+// returns it. It returns nil when no such token exists, which includes a
+// token that has already been used.
+func (r *MongoRepository) ConsumeRefreshToken(tokenHash string) (*model.RefreshToken, error) {
+	collection := r.DB.Collection("refresh_tokens")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"token_hash": tokenHash,
+		"used_at":    bson.M{"$exists": false},
+		"revoked_at": bson.M{"$exists": false},
+	}
+	update := bson.M{"$set": bson.M{"used_at": time.Now()}}
+
+	var token model.RefreshToken
+	err := collection.FindOneAndUpdate(ctx, filter, update).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *MongoRepository) FindRefreshToken(tokenHash string) (*model.RefreshToken, error) {
+	collection := r.DB.Collection("refresh_tokens")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var token model.RefreshToken
+	err := collection.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *MongoRepository) RevokeRefreshTokenFamily(familyID string) error {
+	collection := r.DB.Collection("refresh_tokens")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"family_id": familyID, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revoked_at": time.Now()}}
+
+	_, err := collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (r *MongoRepository) EnsureTokenIndexes() error {
+	collection := r.DB.Collection("refresh_tokens")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "family_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB.Collection("api_keys").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"luma-backend/model"
+)
+
+const aggregationTolerance = 1e-6
+
+// Aggregate re-executes the TAPAS aggregator over the cells its coordinates
+// point at. Coordinates index columns in the order the table was sent to the
+// model, which is the sorted column order shared by the stored schema.
+func Aggregate(table model.TypedTable, response model.Response) model.Aggregation {
+	aggregator := strings.ToUpper(strings.TrimSpace(response.Aggregator))
+	if aggregator == "" {
+		aggregator = "NONE"
+	}
+
+	result := model.Aggregation{
+		Aggregator:  aggregator,
+		Cells:       make([]string, 0, len(response.Coordinates)),
+		ModelAnswer: response.Answer,
+	}
+
+	numbers := make([]float64, 0, len(response.Coordinates))
+	numeric := true
+
+	for i, coordinate := range response.Coordinates {
+		if len(coordinate) != 2 {
+			result.Mismatches = append(result.Mismatches, fmt.Sprintf("coordinate %v is malformed", coordinate))
+			numeric = false
+			continue
+		}
+
+		row, col := coordinate[0], coordinate[1]
+		if row < 0 || row >= len(table.Rows) || col < 0 || col >= len(table.Schema.Columns) {
+			result.Mismatches = append(result.Mismatches, fmt.Sprintf("coordinate (%d, %d) is outside the table", row, col))
+			numeric = false
+			continue
+		}
+
+		value := table.Rows[row][col]
+		result.Cells = append(result.Cells, value.Raw)
+
+		if i < len(response.Cells) && strings.TrimSpace(response.Cells[i]) != value.Raw {
+			result.Mismatches = append(result.Mismatches, fmt.Sprintf("model reported %q at (%d, %d) but the table has %q", response.Cells[i], row, col, value.Raw))
+		}
+
+		columnType := table.Schema.Columns[col].Type
+		if value.Valid && (columnType == model.ColumnFloat || columnType == model.ColumnInt) {
+			numbers = append(numbers, value.Number)
+		} else {
+			numeric = false
+		}
+	}
+
+	if len(response.Cells) != len(response.Coordinates) {
+		result.Mismatches = append(result.Mismatches, fmt.Sprintf("model returned %d cells for %d coordinates", len(response.Cells), len(response.Coordinates)))
+	}
+
+	switch aggregator {
+	case "COUNT":
+		count := float64(len(result.Cells))
+		result.Value = &count
+	case "SUM", "AVERAGE":
+		if !numeric || len(numbers) == 0 {
+			result.Mismatches = append(result.Mismatches, aggregator+" was requested over non-numeric cells")
+			break
+		}
+		total := 0.0
+		for _, n := range numbers {
+			total += n
+		}
+		if aggregator == "AVERAGE" {
+			total /= float64(len(numbers))
+		}
+		result.Value = &total
+	default:
+		if numeric && len(numbers) == 1 {
+			result.Value = &numbers[0]
+		}
+	}
+
+	if result.Value != nil && aggregator != "COUNT" {
+		if stated, ok := statedNumber(response.Answer); ok && math.Abs(stated-*result.Value) > aggregationTolerance {
+			result.Mismatches = append(result.Mismatches, fmt.Sprintf("model answered %v but the table gives %v", stated, *result.Value))
+		}
+	}
+
+	result.Match = len(result.Mismatches) == 0
+	return result
+}
+
+// statedNumber extracts a single number from a TAPAS answer such as
+// "SUM > 3.6" or "1.2". Answers listing several cells are not a stated total.
+func statedNumber(answer string) (float64, bool) {
+	if index := strings.Index(answer, ">"); index >= 0 {
+		answer = answer[index+1:]
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" || strings.Contains(answer, ", ") {
+		return 0, false
+	}
+
+	n, err := strconv.ParseFloat(answer, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"luma-backend/model"
+)
+
+const (
+	DefaultAnomalyThreshold = 3.5
+	minBaselineSamples      = 8
+	minFallbackSamples      = 3
+)
+
+type AnomalyQuery struct {
+	Appliance string
+	From      time.Time
+	To        time.Time
+	Threshold float64
+	Limit     int
+}
+
+type baseline struct {
+	level       string
+	median      float64
+	scale       float64
+	samples     int
+	temperature float64
+}
+
+// Anomalies flags readings in the query window that stray from what the
+// appliance usually draws. Baselines are learned from the whole dataset.
+func (s *EnergyService) Anomalies(email, householdID string, query AnomalyQuery) ([]model.Anomaly, error) {
+	records, err := s.Records(email, householdID)
+	if err != nil {
+		return nil, err
+	}
+	if query.Threshold <= 0 {
+		query.Threshold = DefaultAnomalyThreshold
+	}
+
+	anomalies := DetectAnomalies(records, recordsBetween(records, query.From, query.To), query.Threshold)
+	if query.Appliance != "" {
+		filtered := anomalies[:0]
+		for _, anomaly := range anomalies {
+			if strings.EqualFold(anomaly.Appliance, query.Appliance) {
+				filtered = append(filtered, anomaly)
+			}
+		}
+		anomalies = filtered
+	}
+	if query.Limit > 0 && len(anomalies) > query.Limit {
+		anomalies = anomalies[:query.Limit]
+	}
+	return anomalies, nil
+}
+
+// DetectAnomalies scores each record in window against the median and
+// median absolute deviation of its appliance at the same hour and season
+// (the modified z-score of Iglewicz and Hoaglin). Groups with too few
+// readings fall back to the appliance in that season, then to the appliance
+// overall. Results are ordered by score, most unusual first.
+func DetectAnomalies(history, window []model.EnergyRecord, threshold float64) []model.Anomaly {
+	groups := map[string][]model.EnergyRecord{}
+	for _, record := range history {
+		for _, key := range baselineKeys(record) {
+			groups[key] = append(groups[key], record)
+		}
+	}
+
+	baselines := map[string]baseline{}
+	anomalies := []model.Anomaly{}
+	for _, record := range window {
+		keys := baselineKeys(record)
+		var chosen *baseline
+		for i, key := range keys {
+			members := groups[key]
+			if len(members) < minBaselineSamples && !(i == len(keys)-1 && len(members) >= minFallbackSamples) {
+				continue
+			}
+			b, ok := baselines[key]
+			if !ok {
+				b = newBaseline(baselineLevels[i], members)
+				baselines[key] = b
+			}
+			chosen = &b
+			break
+		}
+		if chosen == nil {
+			continue
+		}
+
+		score := 0.6745 * (record.KWh - chosen.median) / chosen.scale
+		if math.Abs(score) < threshold {
+			continue
+		}
+
+		anomaly := model.Anomaly{
+			Timestamp:   record.Timestamp,
+			Appliance:   record.Appliance,
+			Room:        record.Room,
+			Season:      record.Season,
+			KWh:         record.KWh,
+			ExpectedKWh: roundKWh(chosen.median),
+			Score:       math.Round(math.Abs(score)*100) / 100,
+			Direction:   "high",
+			Baseline:    chosen.level,
+			Samples:     chosen.samples,
+			Temperature: record.Temperature,
+		}
+		if score < 0 {
+			anomaly.Direction = "low"
+		}
+		anomaly.Explanation = explainAnomaly(record, anomaly, *chosen)
+		anomalies = append(anomalies, anomaly)
+	}
+
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].Score > anomalies[j].Score
+	})
+	return anomalies
+}
+
+var baselineLevels = []string{"hour_season", "season", "appliance"}
+
+// baselineKeys lists a record's baseline groups from most to least specific,
+// in the order of baselineLevels.
+func baselineKeys(record model.EnergyRecord) []string {
+	appliance := strings.ToLower(record.Appliance)
+	season := strings.ToLower(record.Season)
+	return []string{
+		fmt.Sprintf("%s|%s|%02d", appliance, season, record.Timestamp.Hour()),
+		appliance + "|" + season,
+		appliance,
+	}
+}
+
+func newBaseline(level string, records []model.EnergyRecord) baseline {
+	values := make([]float64, len(records))
+	var temperatures []float64
+	for i, record := range records {
+		values[i] = record.KWh
+		if record.Temperature != nil {
+			temperatures = append(temperatures, *record.Temperature)
+		}
+	}
+
+	center := median(values)
+	deviations := make([]float64, len(values))
+	for i, value := range values {
+		deviations[i] = math.Abs(value - center)
+	}
+
+	// A constant load such as a refrigerator has no spread at all, which
+	// would make any change infinitely surprising; assume 5% jitter instead.
+	scale := median(deviations)
+	if scale == 0 {
+		scale = math.Max(0.05*math.Abs(center), 0.01)
+	}
+
+	b := baseline{level: level, median: center, scale: scale, samples: len(values), temperature: math.NaN()}
+	if len(temperatures) > 0 {
+		b.temperature = median(temperatures)
+	}
+	return b
+}
+
+func explainAnomaly(record model.EnergyRecord, anomaly model.Anomaly, b baseline) string {
+	var context string
+	switch b.level {
+	case "hour_season":
+		context = fmt.Sprintf("at %02d:00", record.Timestamp.Hour())
+		if record.Season != "" {
+			context += " in " + record.Season
+		}
+	case "season":
+		context = "in " + record.Season
+	default:
+		context = "overall"
+	}
+
+	when := record.Timestamp.Format("2006-01-02 15:04")
+	var explanation string
+	if b.median > 0 {
+		explanation = fmt.Sprintf("%s used %.2f kWh on %s, %.1fx the usual %.2f kWh %s (based on %d readings).",
+			record.Appliance, record.KWh, when, record.KWh/b.median, b.median, context, b.samples)
+	} else {
+		explanation = fmt.Sprintf("%s used %.2f kWh on %s while it usually draws nothing %s (based on %d readings).",
+			record.Appliance, record.KWh, when, context, b.samples)
+	}
+
+	if record.Temperature != nil && !math.IsNaN(b.temperature) {
+		difference := *record.Temperature - b.temperature
+		switch {
+		case difference >= 3:
+			explanation += fmt.Sprintf(" It was %.0f° warmer than usual for these readings.", difference)
+		case difference <= -3:
+			explanation += fmt.Sprintf(" It was %.0f° colder than usual for these readings.", -difference)
+		}
+	}
+	return explanation
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"luma-backend/model"
+)
+
+// fridgeHistory has ten days of a refrigerator drawing about 0.2 kWh at
+// 10:00 and 0.3 kWh at 14:00 in Winter.
+func fridgeHistory() []model.EnergyRecord {
+	jitter := []float64{0, 0.01, -0.01, 0.02, -0.02, 0, 0.01, -0.01, 0.02, -0.02}
+	var records []model.EnergyRecord
+	for day := 0; day < 10; day++ {
+		date := time.Date(2023, time.January, day+1, 0, 0, 0, 0, time.UTC)
+		records = append(records,
+			model.EnergyRecord{Timestamp: date.Add(10 * time.Hour), Appliance: "Refrigerator", Room: "Kitchen", Season: "Winter", KWh: 0.2 + jitter[day]},
+			model.EnergyRecord{Timestamp: date.Add(14 * time.Hour), Appliance: "Refrigerator", Room: "Kitchen", Season: "Winter", KWh: 0.3 + jitter[day]},
+		)
+	}
+	return records
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	day := time.Date(2023, time.January, 11, 0, 0, 0, 0, time.UTC)
+	reading := func(hour int, appliance, season string, kwh float64) model.EnergyRecord {
+		return model.EnergyRecord{Timestamp: day.Add(time.Duration(hour) * time.Hour), Appliance: appliance, Room: "Kitchen", Season: season, KWh: kwh}
+	}
+
+	tests := []struct {
+		name          string
+		history       []model.EnergyRecord
+		record        model.EnergyRecord
+		threshold     float64
+		wantDirection string
+		wantBaseline  string
+	}{
+		{"usual reading", fridgeHistory(), reading(10, "Refrigerator", "Winter", 0.21), DefaultAnomalyThreshold, "", ""},
+		{"spike against the same hour", fridgeHistory(), reading(10, "Refrigerator", "Winter", 0.6), DefaultAnomalyThreshold, "high", "hour_season"},
+		{"drop against the same hour", fridgeHistory(), reading(14, "Refrigerator", "Winter", 0.05), DefaultAnomalyThreshold, "low", "hour_season"},
+		{"usual at 14:00 is unusual at 10:00", fridgeHistory(), reading(10, "Refrigerator", "Winter", 0.3), DefaultAnomalyThreshold, "high", "hour_season"},
+		{"higher threshold lets it pass", fridgeHistory(), reading(10, "Refrigerator", "Winter", 0.3), 20, "", ""},
+		{"unseen hour falls back to the season", fridgeHistory(), reading(3, "Refrigerator", "Winter", 2), DefaultAnomalyThreshold, "high", "season"},
+		{"unseen season falls back to the appliance", fridgeHistory(), reading(10, "Refrigerator", "Summer", 2), DefaultAnomalyThreshold, "high", "appliance"},
+		{"unknown appliance is skipped", fridgeHistory(), reading(10, "Heater", "Winter", 5), DefaultAnomalyThreshold, "", ""},
+		{
+			"constant load still flags a change",
+			[]model.EnergyRecord{
+				reading(1, "Router", "", 0.01), reading(2, "Router", "", 0.01), reading(3, "Router", "", 0.01),
+			},
+			reading(4, "Router", "", 0.1), DefaultAnomalyThreshold, "high", "appliance",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			anomalies := DetectAnomalies(test.history, []model.EnergyRecord{test.record}, test.threshold)
+			if test.wantDirection == "" {
+				if len(anomalies) != 0 {
+					t.Errorf("flagged %+v, want nothing", anomalies)
+				}
+				return
+			}
+			if len(anomalies) != 1 {
+				t.Fatalf("got %d anomalies, want 1", len(anomalies))
+			}
+			anomaly := anomalies[0]
+			if anomaly.Direction != test.wantDirection || anomaly.Baseline != test.wantBaseline {
+				t.Errorf("anomaly = %s against %s, want %s against %s", anomaly.Direction, anomaly.Baseline, test.wantDirection, test.wantBaseline)
+			}
+			if anomaly.Score < test.threshold || anomaly.Explanation == "" {
+				t.Errorf("anomaly = %+v, want a score of at least %v and an explanation", anomaly, test.threshold)
+			}
+		})
+	}
+}
+
+func TestDetectAnomaliesOrdersByScore(t *testing.T) {
+	day := time.Date(2023, time.January, 11, 10, 0, 0, 0, time.UTC)
+	window := []model.EnergyRecord{
+		{Timestamp: day, Appliance: "Refrigerator", Season: "Winter", KWh: 0.5},
+		{Timestamp: day, Appliance: "Refrigerator", Season: "Winter", KWh: 0.9},
+		{Timestamp: day, Appliance: "Refrigerator", Season: "Winter", KWh: 0.2},
+	}
+
+	anomalies := DetectAnomalies(fridgeHistory(), window, DefaultAnomalyThreshold)
+	if len(anomalies) != 2 || anomalies[0].KWh != 0.9 || anomalies[1].KWh != 0.5 {
+		t.Errorf("anomalies = %+v, want 0.9 then 0.5 kWh", anomalies)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   float64
+	}{
+		{nil, 0},
+		{[]float64{3}, 3},
+		{[]float64{5, 1, 3}, 3},
+		{[]float64{4, 1, 3, 2}, 2.5},
+	}
+
+	for _, test := range tests {
+		if got := median(test.values); got != test.want {
+			t.Errorf("median(%v) = %v, want %v", test.values, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"luma-backend/model"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix marks personal API keys so the middleware can tell them apart
+// from JWTs sent in the same Authorization header.
+const APIKeyPrefix = "luma_"
+
+const apiKeyTouchInterval = time.Minute
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidScope   = errors.New("invalid scope")
+)
+
+// CreateAPIKey returns the raw key together with its stored record. The raw
+// key is only available here; afterwards just its hash is kept.
+func (s *AuthService) CreateAPIKey(email, name string, scopes []string) (string, model.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "API key"
+	}
+	if len(scopes) == 0 {
+		return "", model.APIKey{}, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+
+	seen := make(map[string]bool, len(scopes))
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return "", model.APIKey{}, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", model.APIKey{}, err
+	}
+	raw := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	key := model.APIKey{
+		ID:        uuid.New().String(),
+		Email:     email,
+		Name:      name,
+		Prefix:    raw[:len(APIKeyPrefix)+6],
+		KeyHash:   hashToken(raw),
+		Scopes:    normalized,
+		CreatedAt: time.Now(),
+	}
+
+	err := s.MongoRepo.SaveAPIKey(key)
+	if err != nil {
+		return "", model.APIKey{}, err
+	}
+	return raw, key, nil
+}
+
+func (s *AuthService) ListAPIKeys(email string) ([]model.APIKey, error) {
+	return s.MongoRepo.ListAPIKeys(email)
+}
+
+func (s *AuthService) RevokeAPIKey(email, keyID string) error {
+	found, err := s.MongoRepo.RevokeAPIKey(email, keyID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey resolves a raw key to its active record and records the
+// use for the last-used timestamp shown in the key list.
+func (s *AuthService) AuthenticateAPIKey(raw string) (model.APIKey, error) {
+	if !strings.HasPrefix(raw, APIKeyPrefix) {
+		return model.APIKey{}, ErrInvalidAPIKey
+	}
+
+	key, err := s.MongoRepo.FindAPIKeyByHash(hashToken(raw))
+	if err != nil {
+		return model.APIKey{}, err
+	}
+	if key == nil || key.RevokedAt != nil {
+		return model.APIKey{}, ErrInvalidAPIKey
+	}
+
+	err = s.MongoRepo.TouchAPIKey(key.ID, apiKeyTouchInterval)
+	if err != nil {
+		return model.APIKey{}, err
+	}
+	return *key, nil
+}
+
+func validScope(scope string) bool {
+	for _, s := range model.APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"luma-backend/model"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	auth, store := newTestAuth(t)
+
+	raw, key, err := auth.CreateAPIKey("alice@example.com", "  ", []string{model.ScopeChatRead, model.ScopeChatRead, model.ScopeDatasetsRead})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	if !strings.HasPrefix(raw, APIKeyPrefix) || !strings.HasPrefix(raw, key.Prefix) {
+		t.Errorf("raw key %q, prefix %q, want %q keys starting with the shown prefix", raw, key.Prefix, APIKeyPrefix)
+	}
+	stored := store.apiKeys[key.ID]
+	if stored.KeyHash != hashToken(raw) || strings.Contains(stored.KeyHash, raw) {
+		t.Errorf("stored hash %q, want only the SHA-256 of the raw key", stored.KeyHash)
+	}
+	if key.Name != "API key" || len(key.Scopes) != 2 {
+		t.Errorf("key = %+v, want the default name and two distinct scopes", key)
+	}
+
+	for _, scopes := range [][]string{nil, {"admin"}} {
+		if _, _, err := auth.CreateAPIKey("alice@example.com", "ci", scopes); !errors.Is(err, ErrInvalidScope) {
+			t.Errorf("scopes %v: err = %v, want ErrInvalidScope", scopes, err)
+		}
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	auth, _ := newTestAuth(t)
+	active, _, err := auth.CreateAPIKey("alice@example.com", "ci", []string{model.ScopeChatRead})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	revoked, revokedKey, _ := auth.CreateAPIKey("alice@example.com", "old", []string{model.ScopeChatRead})
+	if err := auth.RevokeAPIKey("bob@example.com", revokedKey.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("revoking another user's key: err = %v, want ErrAPIKeyNotFound", err)
+	}
+	if err := auth.RevokeAPIKey("alice@example.com", revokedKey.ID); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		raw  string
+		want error
+	}{
+		{"active key", active, nil},
+		{"revoked key", revoked, ErrInvalidAPIKey},
+		{"unknown key", APIKeyPrefix + "unknown", ErrInvalidAPIKey},
+		{"wrong prefix", strings.TrimPrefix(active, APIKeyPrefix), ErrInvalidAPIKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := auth.AuthenticateAPIKey(test.raw)
+			if !errors.Is(err, test.want) {
+				t.Fatalf("err = %v, want %v", err, test.want)
+			}
+			if err == nil && key.Email != "alice@example.com" {
+				t.Errorf("key = %+v, want alice's key", key)
+			}
+		})
+	}
+
+	keys, _ := auth.ListAPIKeys("alice@example.com")
+	if len(keys) != 1 || keys[0].LastUsedAt == nil {
+		t.Errorf("ListAPIKeys = %+v, want the active key with its last use recorded", keys)
+	}
+}
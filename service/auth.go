@@ -0,0 +1,243 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"time"
+
+	"luma-backend/model"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+const (
+	AccessTokenTTL = 15 * time.Minute
+	SessionTTL     = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidToken        = errors.New("invalid token")
+	ErrSessionRevoked      = errors.New("session is no longer active")
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// AuthStore persists login sessions, their refresh tokens and personal API
+// keys.
+type AuthStore interface {
+	SaveSession(session model.Session) error
+	FindSession(sessionID string) (*model.Session, error)
+	ListActiveSessions(email string) ([]model.Session, error)
+	RevokeSession(email, sessionID string) (bool, error)
+	SaveRefreshToken(token model.RefreshToken) error
+	ConsumeRefreshToken(tokenHash string) (*model.RefreshToken, error)
+	FindRefreshToken(tokenHash string) (*model.RefreshToken, error)
+	RevokeRefreshTokenFamily(familyID string) error
+	SaveAPIKey(key model.APIKey) error
+	FindAPIKeyByHash(keyHash string) (*model.APIKey, error)
+	ListAPIKeys(email string) ([]model.APIKey, error)
+	RevokeAPIKey(email, keyID string) (bool, error)
+	TouchAPIKey(keyID string, interval time.Duration) error
+}
+
+type AuthService struct {
+	MongoRepo AuthStore
+	Keys      *KeySet
+}
+
+func (s *AuthService) CreateSession(user model.User, userAgent, ip string) (model.Session, error) {
+	now := time.Now()
+	session := model.Session{
+		ID:        uuid.New().String(),
+		User:      user,
+		UserAgent: userAgent,
+		IP:        ip,
+		CreatedAt: now,
+		ExpiresAt: now.Add(SessionTTL),
+	}
+
+	err := s.MongoRepo.SaveSession(session)
+	if err != nil {
+		return model.Session{}, err
+	}
+	return session, nil
+}
+
+func (s *AuthService) IssueToken(user model.User, sessionID string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"email":   user.Email,
+		"name":    user.Name,
+		"picture": user.Picture,
+		"sid":     sessionID,
+		"iat":     now.Unix(),
+		"exp":     now.Add(AccessTokenTTL).Unix(),
+	}
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		claims["iss"] = issuer
+	}
+
+	return s.Keys.Sign(claims)
+}
+
+func (s *AuthService) ParseToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, s.Keys.Keyfunc)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// Authenticate parses a token and checks that the session it was issued for
+// is still active, so revoked sessions are rejected before the JWT expires.
+func (s *AuthService) Authenticate(tokenString string) (jwt.MapClaims, error) {
+	claims, err := s.ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, _ := claims["sid"].(string)
+	email, _ := claims["email"].(string)
+	if sessionID == "" || email == "" {
+		return nil, ErrSessionRevoked
+	}
+
+	session, err := s.MongoRepo.FindSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.User.Email != email || session.RevokedAt != nil {
+		return nil, ErrSessionRevoked
+	}
+	if !session.ExpiresAt.IsZero() && time.Now().After(session.ExpiresAt) {
+		return nil, ErrSessionRevoked
+	}
+
+	return claims, nil
+}
+
+func (s *AuthService) ListSessions(email, currentSessionID string) ([]model.Session, error) {
+	sessions, err := s.MongoRepo.ListActiveSessions(email)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+func (s *AuthService) RevokeSession(email, sessionID string) error {
+	found, err := s.MongoRepo.RevokeSession(email, sessionID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrSessionNotFound
+	}
+	return s.MongoRepo.RevokeRefreshTokenFamily(sessionID)
+}
+
+// IssueRefreshToken returns a new opaque refresh token for the session. Only
+// its hash is stored; the session ID doubles as the rotation family ID.
+func (s *AuthService) IssueRefreshToken(session model.Session) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(buf)
+
+	err := s.MongoRepo.SaveRefreshToken(model.RefreshToken{
+		TokenHash: hashToken(raw),
+		FamilyID:  session.ID,
+		Email:     session.User.Email,
+		CreatedAt: time.Now(),
+		ExpiresAt: session.ExpiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// Refresh rotates a refresh token, returning a new access token and refresh
+// token for the same session. Presenting a token that was already rotated
+// means it leaked, so the whole family and its session are revoked.
+func (s *AuthService) Refresh(raw string) (string, string, model.Session, error) {
+	hash := hashToken(raw)
+	token, err := s.MongoRepo.ConsumeRefreshToken(hash)
+	if err != nil {
+		return "", "", model.Session{}, err
+	}
+
+	if token == nil {
+		previous, err := s.MongoRepo.FindRefreshToken(hash)
+		if err != nil {
+			return "", "", model.Session{}, err
+		}
+		if previous == nil {
+			return "", "", model.Session{}, ErrInvalidRefreshToken
+		}
+
+		if err := s.MongoRepo.RevokeRefreshTokenFamily(previous.FamilyID); err != nil {
+			return "", "", model.Session{}, err
+		}
+		if _, err := s.MongoRepo.RevokeSession(previous.Email, previous.FamilyID); err != nil {
+			return "", "", model.Session{}, err
+		}
+		return "", "", model.Session{}, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		return "", "", model.Session{}, ErrInvalidRefreshToken
+	}
+
+	session, err := s.MongoRepo.FindSession(token.FamilyID)
+	if err != nil {
+		return "", "", model.Session{}, err
+	}
+	if session == nil || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return "", "", model.Session{}, ErrSessionRevoked
+	}
+
+	accessToken, err := s.IssueToken(session.User, session.ID)
+	if err != nil {
+		return "", "", model.Session{}, err
+	}
+
+	refreshToken, err := s.IssueRefreshToken(*session)
+	if err != nil {
+		return "", "", model.Session{}, err
+	}
+
+	return accessToken, refreshToken, *session, nil
+}
+
+// RevokeRefreshToken ends the session a refresh token belongs to. Logout
+// relies on it once the short-lived access token has already expired.
+func (s *AuthService) RevokeRefreshToken(raw string) error {
+	token, err := s.MongoRepo.FindRefreshToken(hashToken(raw))
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return ErrInvalidRefreshToken
+	}
+	return s.RevokeSession(token.Email, token.FamilyID)
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
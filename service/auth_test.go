@@ -0,0 +1,330 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"luma-backend/model"
+)
+
+// memoryAuthStore keeps sessions, refresh tokens and API keys in maps with
+// the same matching rules as the MongoDB queries.
+type memoryAuthStore struct {
+	mu       sync.Mutex
+	sessions map[string]model.Session
+	tokens   map[string]model.RefreshToken
+	apiKeys  map[string]model.APIKey
+}
+
+func newMemoryAuthStore() *memoryAuthStore {
+	return &memoryAuthStore{
+		sessions: map[string]model.Session{},
+		tokens:   map[string]model.RefreshToken{},
+		apiKeys:  map[string]model.APIKey{},
+	}
+}
+
+func (m *memoryAuthStore) SaveSession(session model.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session.ID] = session
+	return nil
+}
+
+func (m *memoryAuthStore) FindSession(sessionID string) (*model.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (m *memoryAuthStore) ListActiveSessions(email string) ([]model.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sessions := []model.Session{}
+	for _, session := range m.sessions {
+		if session.User.Email == email && session.RevokedAt == nil && time.Now().Before(session.ExpiresAt) {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (m *memoryAuthStore) RevokeSession(email, sessionID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[sessionID]
+	if !ok || session.User.Email != email {
+		return false, nil
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	m.sessions[sessionID] = session
+	return true, nil
+}
+
+func (m *memoryAuthStore) SaveRefreshToken(token model.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (m *memoryAuthStore) ConsumeRefreshToken(tokenHash string) (*model.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.tokens[tokenHash]
+	if !ok || token.UsedAt != nil || token.RevokedAt != nil {
+		return nil, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	m.tokens[tokenHash] = token
+	return &token, nil
+}
+
+func (m *memoryAuthStore) FindRefreshToken(tokenHash string) (*model.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.tokens[tokenHash]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+func (m *memoryAuthStore) RevokeRefreshTokenFamily(familyID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for hash, token := range m.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			m.tokens[hash] = token
+		}
+	}
+	return nil
+}
+
+func (m *memoryAuthStore) SaveAPIKey(key model.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.apiKeys[key.ID] = key
+	return nil
+}
+
+func (m *memoryAuthStore) FindAPIKeyByHash(keyHash string) (*model.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range m.apiKeys {
+		if key.KeyHash == keyHash {
+			return &key, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryAuthStore) ListAPIKeys(email string) ([]model.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := []model.APIKey{}
+	for _, key := range m.apiKeys {
+		if key.Email == email && key.RevokedAt == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (m *memoryAuthStore) RevokeAPIKey(email, keyID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.apiKeys[keyID]
+	if !ok || key.Email != email || key.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	m.apiKeys[keyID] = key
+	return true, nil
+}
+
+func (m *memoryAuthStore) TouchAPIKey(keyID string, interval time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.apiKeys[keyID]
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	if key.LastUsedAt == nil || key.LastUsedAt.Before(now.Add(-interval)) {
+		key.LastUsedAt = &now
+		m.apiKeys[keyID] = key
+	}
+	return nil
+}
+
+func newTestAuth(t *testing.T) (*AuthService, *memoryAuthStore) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	id := keyID(&key.PublicKey)
+	keys := &KeySet{signingID: id, signingKey: key, publicKeys: map[string]*rsa.PublicKey{id: &key.PublicKey}}
+
+	store := newMemoryAuthStore()
+	return &AuthService{MongoRepo: store, Keys: keys}, store
+}
+
+func newTestSession(t *testing.T, auth *AuthService, email string) (model.Session, string) {
+	t.Helper()
+	session, err := auth.CreateSession(model.User{Email: email, Name: "Alice"}, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	refresh, err := auth.IssueRefreshToken(session)
+	if err != nil {
+		t.Fatalf("IssueRefreshToken: %v", err)
+	}
+	return session, refresh
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	auth, store := newTestAuth(t)
+	session, first := newTestSession(t, auth, "alice@example.com")
+
+	access, second, refreshed, err := auth.Refresh(first)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if second == first || refreshed.ID != session.ID {
+		t.Errorf("rotation returned refresh token %q for session %s, want a new token for %s", second, refreshed.ID, session.ID)
+	}
+	if used := store.tokens[hashToken(first)].UsedAt; used == nil {
+		t.Error("the rotated refresh token was not marked used")
+	}
+	claims, err := auth.Authenticate(access)
+	if err != nil || claims["sid"] != session.ID {
+		t.Errorf("Authenticate(new access token) = %v, %v, want the same session", claims, err)
+	}
+
+	if _, _, _, err := auth.Refresh(second); err != nil {
+		t.Errorf("Refresh(rotated token): %v", err)
+	}
+}
+
+func TestRefreshReuseRevokesFamilyAndSession(t *testing.T) {
+	auth, store := newTestAuth(t)
+	session, first := newTestSession(t, auth, "alice@example.com")
+	access, second, _, err := auth.Refresh(first)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	if _, _, _, err := auth.Refresh(first); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replaying a used token: err = %v, want ErrRefreshTokenReused", err)
+	}
+
+	if store.sessions[session.ID].RevokedAt == nil {
+		t.Error("session still active after refresh token reuse")
+	}
+	for hash, token := range store.tokens {
+		if token.RevokedAt == nil {
+			t.Errorf("refresh token %s still active after reuse", hash)
+		}
+	}
+	if _, _, _, err := auth.Refresh(second); err == nil {
+		t.Error("the token issued before the replay still refreshes")
+	}
+	if _, err := auth.Authenticate(access); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Authenticate after reuse: err = %v, want ErrSessionRevoked", err)
+	}
+}
+
+func TestRefreshRejectsExpiredAndUnknownTokens(t *testing.T) {
+	auth, store := newTestAuth(t)
+	session := model.Session{ID: "expired", User: model.User{Email: "alice@example.com"}, ExpiresAt: time.Now().Add(-time.Minute)}
+	store.SaveSession(session)
+	expired, err := auth.IssueRefreshToken(session)
+	if err != nil {
+		t.Fatalf("IssueRefreshToken: %v", err)
+	}
+
+	for name, raw := range map[string]string{"expired": expired, "unknown": "not-a-token"} {
+		if _, _, _, err := auth.Refresh(raw); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("%s token: err = %v, want ErrInvalidRefreshToken", name, err)
+		}
+	}
+	if store.sessions[session.ID].RevokedAt != nil {
+		t.Error("an expired token was treated as reuse and revoked the session")
+	}
+}
+
+func TestAuthenticateChecksSession(t *testing.T) {
+	auth, store := newTestAuth(t)
+	session, _ := newTestSession(t, auth, "alice@example.com")
+	token := func(t *testing.T, email, sessionID string) string {
+		t.Helper()
+		raw, err := auth.IssueToken(model.User{Email: email}, sessionID)
+		if err != nil {
+			t.Fatalf("IssueToken: %v", err)
+		}
+		return raw
+	}
+
+	expired := model.Session{ID: "expired", User: model.User{Email: "alice@example.com"}, ExpiresAt: time.Now().Add(-time.Minute)}
+	store.SaveSession(expired)
+	revoked, _ := newTestSession(t, auth, "alice@example.com")
+	if err := auth.RevokeSession("alice@example.com", revoked.ID); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"active session", token(t, "alice@example.com", session.ID), nil},
+		{"revoked session", token(t, "alice@example.com", revoked.ID), ErrSessionRevoked},
+		{"expired session", token(t, "alice@example.com", expired.ID), ErrSessionRevoked},
+		{"unknown session", token(t, "alice@example.com", "missing"), ErrSessionRevoked},
+		{"no session", token(t, "alice@example.com", ""), ErrSessionRevoked},
+		{"session of another user", token(t, "bob@example.com", session.ID), ErrSessionRevoked},
+		{"not a JWT", "garbage", ErrInvalidToken},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := auth.Authenticate(test.token); !errors.Is(err, test.want) {
+				t.Errorf("err = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestRevokeSessionEndsRefreshTokens(t *testing.T) {
+	auth, _ := newTestAuth(t)
+	session, refresh := newTestSession(t, auth, "alice@example.com")
+
+	if err := auth.RevokeSession("bob@example.com", session.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("revoking another user's session: err = %v, want ErrSessionNotFound", err)
+	}
+	if err := auth.RevokeSession("alice@example.com", session.ID); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if _, _, _, err := auth.Refresh(refresh); err == nil {
+		t.Error("refresh token still works after its session was revoked")
+	}
+	sessions, _ := auth.ListSessions("alice@example.com", session.ID)
+	if len(sessions) != 0 {
+		t.Errorf("ListSessions = %+v, want no active sessions", sessions)
+	}
+}
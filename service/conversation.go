@@ -0,0 +1,257 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"luma-backend/model"
+	"luma-backend/repository"
+
+	"github.com/google/uuid"
+)
+
+const maxTitleLength = 60
+
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrForbidden            = errors.New("forbidden")
+)
+
+// SessionOwners resolves conversation IDs that predate conversations, which
+// were the login session ID, to the email that logged in with them.
+type SessionOwners interface {
+	FindSessionOwner(sessionID string) (string, error)
+}
+
+// HouseholdMembers lists the emails whose conversations a user may read.
+type HouseholdMembers interface {
+	MemberEmails(email string) ([]string, error)
+}
+
+type ConversationService struct {
+	ChatRepo   repository.ChatStore
+	MongoRepo  SessionOwners
+	Households HouseholdMembers
+}
+
+func (s *ConversationService) Create(email, title string) (model.Conversation, error) {
+	now := time.Now()
+	conversation := model.Conversation{
+		ID:        uuid.New().String(),
+		Email:     email,
+		Title:     strings.TrimSpace(title),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err := s.ChatRepo.CreateConversation(conversation)
+	if err != nil {
+		return model.Conversation{}, err
+	}
+	return conversation, nil
+}
+
+// List returns the caller's conversations together with those of the other
+// members of their household.
+func (s *ConversationService) List(email string) ([]model.Conversation, error) {
+	emails, err := s.Households.MemberEmails(email)
+	if err != nil {
+		return nil, err
+	}
+	return s.ChatRepo.ListConversations(emails)
+}
+
+func (s *ConversationService) Rename(email, conversationID, title string) error {
+	err := s.authorizeOwned(email, conversationID)
+	if err != nil {
+		return err
+	}
+
+	found, err := s.ChatRepo.RenameConversation(email, conversationID, strings.TrimSpace(title))
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrConversationNotFound
+	}
+	return nil
+}
+
+func (s *ConversationService) Delete(email, conversationID string) error {
+	err := s.authorizeOwned(email, conversationID)
+	if err != nil {
+		return err
+	}
+
+	found, err := s.ChatRepo.DeleteConversation(email, conversationID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrConversationNotFound
+	}
+	return nil
+}
+
+// Authorize checks that email owns the conversation.
+func (s *ConversationService) Authorize(email, conversationID string) error {
+	owner, err := s.owner(conversationID)
+	if err != nil {
+		return err
+	}
+	if owner != email {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// authorizeOwned checks that email owns the conversation and, for IDs that
+// predate conversations, creates the conversation document so that it can be
+// renamed or deleted like any other.
+func (s *ConversationService) authorizeOwned(email, conversationID string) error {
+	err := s.Authorize(email, conversationID)
+	if err != nil {
+		return err
+	}
+
+	conversation, err := s.ChatRepo.GetConversation(conversationID)
+	if err != nil || conversation != nil {
+		return err
+	}
+
+	messages, err := s.ChatRepo.GetMessages(conversationID)
+	if err != nil {
+		return err
+	}
+	created := time.Now()
+	title := ""
+	for _, message := range messages {
+		if message.Role == "user" && len(message.Parts) > 0 {
+			title = titleFromQuery(message.Parts[0].Text)
+			break
+		}
+	}
+	if len(messages) > 0 && !messages[0].CreatedAt.IsZero() {
+		created = messages[0].CreatedAt
+	}
+	return s.ChatRepo.CreateConversation(model.Conversation{
+		ID:        conversationID,
+		Email:     email,
+		Title:     title,
+		CreatedAt: created,
+		UpdatedAt: time.Now(),
+	})
+}
+
+// AuthorizeRead checks that email may read the conversation, which household
+// members may do for each other's conversations.
+func (s *ConversationService) AuthorizeRead(email, conversationID string) error {
+	owner, err := s.owner(conversationID)
+	if err != nil {
+		return err
+	}
+
+	emails, err := s.Households.MemberEmails(email)
+	if err != nil {
+		return err
+	}
+	for _, member := range emails {
+		if member == owner {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
+
+// owner returns the email that owns a conversation. IDs that predate
+// conversations are owned by whoever logged in with that session ID.
+func (s *ConversationService) owner(conversationID string) (string, error) {
+	conversation, err := s.ChatRepo.GetConversation(conversationID)
+	if err != nil {
+		return "", err
+	}
+	if conversation != nil {
+		return conversation.Email, nil
+	}
+
+	owner, err := s.MongoRepo.FindSessionOwner(conversationID)
+	if err != nil {
+		return "", err
+	}
+	if owner == "" {
+		return "", ErrConversationNotFound
+	}
+	return owner, nil
+}
+
+// RecordTurn registers activity on a conversation the caller owns, creating
+// it when the ID is new and titling it after the first question asked.
+func (s *ConversationService) RecordTurn(email, conversationID, query string) error {
+	err := s.Authorize(email, conversationID)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrConversationNotFound):
+		messages, err := s.ChatRepo.GetMessages(conversationID)
+		if err != nil {
+			return err
+		}
+		if len(messages) > 0 {
+			return ErrForbidden
+		}
+	default:
+		return err
+	}
+
+	title := titleFromQuery(query)
+	conversation, err := s.ChatRepo.GetConversation(conversationID)
+	if err != nil {
+		return err
+	}
+	if conversation == nil {
+		now := time.Now()
+		return s.ChatRepo.CreateConversation(model.Conversation{
+			ID:        conversationID,
+			Email:     email,
+			Title:     title,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+
+	return s.ChatRepo.TouchConversation(conversationID, title)
+}
+
+func (s *ConversationService) Search(email, query string, limit int) ([]model.SearchResult, error) {
+	conversations, err := s.List(email)
+	if err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return []model.SearchResult{}, nil
+	}
+
+	ids := make([]string, 0, len(conversations))
+	titles := make(map[string]string, len(conversations))
+	for _, conversation := range conversations {
+		ids = append(ids, conversation.ID)
+		titles[conversation.ID] = conversation.Title
+	}
+
+	results, err := s.ChatRepo.SearchMessages(ids, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Title = titles[results[i].ConversationID]
+	}
+	return results, nil
+}
+
+func titleFromQuery(query string) string {
+	title := strings.TrimSpace(query)
+	if index := strings.IndexByte(title, '\n'); index >= 0 {
+		title = strings.TrimSpace(title[:index])
+	}
+	return truncateRunes(title, maxTitleLength)
+}
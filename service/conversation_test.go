@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"luma-backend/model"
+	"luma-backend/repository"
+)
+
+type fakeSessionOwners map[string]string
+
+func (f fakeSessionOwners) FindSessionOwner(sessionID string) (string, error) {
+	return f[sessionID], nil
+}
+
+type fakeHouseholds map[string][]string
+
+func (f fakeHouseholds) MemberEmails(email string) ([]string, error) {
+	if emails, ok := f[email]; ok {
+		return emails, nil
+	}
+	return []string{email}, nil
+}
+
+const (
+	alice = "alice@example.com"
+	bob   = "bob@example.com"
+	carol = "carol@example.com"
+)
+
+// newConversationFixture gives alice a conversation, a pre-conversation
+// login session with history, and a household shared with carol.
+func newConversationFixture(t *testing.T) (*ConversationService, *repository.MemoryChatRepository) {
+	t.Helper()
+	chats := repository.NewMemoryChatRepository()
+	now := time.Now()
+	chats.CreateConversation(model.Conversation{ID: "alice-conversation", Email: alice, Title: "Tagihan", CreatedAt: now, UpdatedAt: now})
+	chats.SaveMessage("alice-conversation", model.Message{Role: "user", Parts: []model.Part{{Text: "Berapa tagihan saya?"}}})
+	chats.SaveMessage("alice-session", model.Message{Role: "user", Parts: []model.Part{{Text: "Halo"}}})
+	chats.SaveMessage("orphan-session", model.Message{Role: "user", Parts: []model.Part{{Text: "Halo"}}})
+
+	return &ConversationService{
+		ChatRepo:   chats,
+		MongoRepo:  fakeSessionOwners{"alice-session": alice},
+		Households: fakeHouseholds{alice: {alice, carol}, carol: {alice, carol}},
+	}, chats
+}
+
+func TestConversationCrossUserAccess(t *testing.T) {
+	tests := []struct {
+		name   string
+		action func(s *ConversationService) error
+		want   error
+	}{
+		{"owner reads", func(s *ConversationService) error { return s.AuthorizeRead(alice, "alice-conversation") }, nil},
+		{"owner posts", func(s *ConversationService) error { return s.RecordTurn(alice, "alice-conversation", "Lagi") }, nil},
+		{"owner posts to login session", func(s *ConversationService) error { return s.RecordTurn(alice, "alice-session", "Lagi") }, nil},
+		{"other user reads", func(s *ConversationService) error { return s.AuthorizeRead(bob, "alice-conversation") }, ErrForbidden},
+		{"other user reads login session", func(s *ConversationService) error { return s.AuthorizeRead(bob, "alice-session") }, ErrForbidden},
+		{"other user posts", func(s *ConversationService) error { return s.RecordTurn(bob, "alice-conversation", "Halo") }, ErrForbidden},
+		{"other user posts to login session", func(s *ConversationService) error { return s.RecordTurn(bob, "alice-session", "Halo") }, ErrForbidden},
+		{"other user claims orphaned history", func(s *ConversationService) error { return s.RecordTurn(bob, "orphan-session", "Halo") }, ErrForbidden},
+		{"other user renames", func(s *ConversationService) error { return s.Rename(bob, "alice-conversation", "Milik Bob") }, ErrForbidden},
+		{"other user deletes", func(s *ConversationService) error { return s.Delete(bob, "alice-conversation") }, ErrForbidden},
+		{"household member reads", func(s *ConversationService) error { return s.AuthorizeRead(carol, "alice-conversation") }, nil},
+		{"household member posts", func(s *ConversationService) error { return s.RecordTurn(carol, "alice-conversation", "Halo") }, ErrForbidden},
+		{"household member renames", func(s *ConversationService) error { return s.Rename(carol, "alice-conversation", "Milik Carol") }, ErrForbidden},
+		{"household member deletes", func(s *ConversationService) error { return s.Delete(carol, "alice-conversation") }, ErrForbidden},
+		{"unknown conversation", func(s *ConversationService) error { return s.AuthorizeRead(bob, "missing") }, ErrConversationNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service, chats := newConversationFixture(t)
+
+			err := test.action(service)
+			if !errors.Is(err, test.want) {
+				t.Fatalf("err = %v, want %v", err, test.want)
+			}
+
+			if test.want == nil {
+				return
+			}
+			conversation, _ := chats.GetConversation("alice-conversation")
+			if conversation == nil || conversation.Email != alice || conversation.Title != "Tagihan" {
+				t.Errorf("alice's conversation changed after a rejected request: %+v", conversation)
+			}
+		})
+	}
+}
+
+func TestConversationListAndSearchStayInHousehold(t *testing.T) {
+	service, _ := newConversationFixture(t)
+
+	conversations, err := service.List(bob)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(conversations) != 0 {
+		t.Errorf("bob sees %d of alice's conversations", len(conversations))
+	}
+
+	results, err := service.Search(bob, "tagihan", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("bob's search returned alice's messages: %+v", results)
+	}
+
+	results, err = service.Search(carol, "tagihan", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].ConversationID != "alice-conversation" {
+		t.Errorf("carol's search = %+v, want alice's message", results)
+	}
+}
+
+func TestLoginSessionConversationRenameAndDelete(t *testing.T) {
+	service, chats := newConversationFixture(t)
+
+	if err := service.Rename(alice, "alice-session", "Sapaan"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	conversation, _ := chats.GetConversation("alice-session")
+	if conversation == nil || conversation.Email != alice || conversation.Title != "Sapaan" {
+		t.Errorf("conversation after rename = %+v, want alice's titled Sapaan", conversation)
+	}
+
+	service, chats = newConversationFixture(t)
+	if err := service.Delete(alice, "alice-session"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if messages, _ := chats.GetMessages("alice-session"); len(messages) != 0 {
+		t.Errorf("%d messages left after delete", len(messages))
+	}
+	if conversation, _ := chats.GetConversation("alice-session"); conversation != nil {
+		t.Errorf("conversation left after delete: %+v", conversation)
+	}
+}
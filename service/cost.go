@@ -0,0 +1,439 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"luma-backend/model"
+	"luma-backend/repository"
+)
+
+var ErrInvalidTariff = errors.New("invalid tariff")
+
+// DefaultTariff is PLN's 2023 rate for R-1/TR 1.300 VA households, used until
+// a household configures its own.
+func DefaultTariff() model.Tariff {
+	return model.Tariff{
+		Name:     "PLN R-1/TR 1.300 VA",
+		Type:     model.TariffFlat,
+		Currency: "IDR",
+		FlatRate: 1444.70,
+		Default:  true,
+	}
+}
+
+type CostService struct {
+	DatasetRepo *repository.DatasetRepository
+	Energy      *EnergyService
+}
+
+func (s *CostService) GetTariff(email, householdID string) (model.Tariff, error) {
+	tariff, err := s.DatasetRepo.GetTariff(email, householdID)
+	if err != nil {
+		return model.Tariff{}, err
+	}
+	if tariff == nil {
+		return DefaultTariff(), nil
+	}
+	return *tariff, nil
+}
+
+func (s *CostService) SaveTariff(email, householdID string, tariff model.Tariff) (model.Tariff, error) {
+	if tariff.Type == "" {
+		tariff.Type = model.TariffFlat
+	}
+	if tariff.Currency == "" {
+		tariff.Currency = "IDR"
+	}
+	err := validateTariff(tariff)
+	if err != nil {
+		return model.Tariff{}, err
+	}
+
+	tariff.Email = email
+	tariff.HouseholdID = householdID
+	tariff.Default = false
+	tariff.UpdatedAt = time.Now()
+
+	err = s.DatasetRepo.SaveTariff(tariff)
+	if err != nil {
+		return model.Tariff{}, err
+	}
+	return tariff, nil
+}
+
+// Report prices the dataset's consumption in [from, to) under the caller's
+// tariff. Zero bounds leave that side of the range open.
+func (s *CostService) Report(email, householdID string, from, to time.Time, includeRecords bool) (model.CostReport, error) {
+	records, err := s.Energy.Records(email, householdID)
+	if err != nil {
+		return model.CostReport{}, err
+	}
+	tariff, err := s.GetTariff(email, householdID)
+	if err != nil {
+		return model.CostReport{}, err
+	}
+
+	report := PriceRecordsBetween(tariff, records, from, to)
+	if !includeRecords {
+		report.Records = nil
+	}
+	return report, nil
+}
+
+// PromptNote summarises what the dataset cost so Gemini can answer money
+// questions with the household's own tariff instead of guessing one.
+func (s *CostService) PromptNote(dataset model.Dataset) (string, error) {
+	records, err := EnergyRecords(TypedTable(dataset))
+	if err != nil {
+		return "", err
+	}
+	tariff, err := s.GetTariff(dataset.Email, dataset.HouseholdID)
+	if err != nil {
+		return "", err
+	}
+	if len(records) == 0 {
+		return "", nil
+	}
+
+	report := PriceRecords(tariff, records)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tarif listrik rumah ini: %s.\n", describeTariff(tariff))
+	fmt.Fprintf(&b, "Biaya periode %s sampai %s: %.2f kWh, total %s %.2f (energi %.2f, biaya tetap %.2f, pajak %.2f).\n",
+		report.From.Format("2006-01-02 15:04"), report.To.Format("2006-01-02 15:04"),
+		report.TotalKWh, report.Currency, report.TotalCost, report.EnergyCost, report.FixedCharges, report.Tax)
+	b.WriteString("Biaya per peralatan:")
+	for _, item := range report.ByAppliance {
+		fmt.Fprintf(&b, " %s %.2f kWh = %s %.2f;", item.Key, item.KWh, report.Currency, item.Cost)
+	}
+	return strings.TrimSuffix(b.String(), ";"), nil
+}
+
+// PriceRecords prices every record and totals the whole span they cover.
+func PriceRecords(tariff model.Tariff, records []model.EnergyRecord) model.CostReport {
+	return PriceRecordsBetween(tariff, records, time.Time{}, time.Time{})
+}
+
+// PriceRecordsBetween prices the whole history, so tiered blocks count usage
+// from the start of each calendar month whatever the query window, then
+// totals the records in [from, to). The fixed monthly fee is pro-rated by the
+// share of each month that both the window and the data cover. Tax applies
+// to energy and fixed charges alike. Zero bounds leave that side open. The
+// report's From and To span the priced readings, To being exclusive.
+func PriceRecordsBetween(tariff model.Tariff, history []model.EnergyRecord, from, to time.Time) model.CostReport {
+	report := model.CostReport{
+		Tariff:      tariff,
+		Currency:    tariff.Currency,
+		ByAppliance: []model.CostBreakdown{},
+		ByRoom:      []model.CostBreakdown{},
+		ByMonth:     []model.CostBreakdown{},
+		Records:     []model.CostedRecord{},
+	}
+	taxRate := tariff.TaxPercent / 100
+
+	appliances := map[string]*model.CostBreakdown{}
+	rooms := map[string]*model.CostBreakdown{}
+	months := map[string]*model.CostBreakdown{}
+
+	for _, costed := range costRecords(tariff, history) {
+		if !from.IsZero() && costed.Timestamp.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !costed.Timestamp.Before(to) {
+			continue
+		}
+		report.Records = append(report.Records, costed)
+
+		report.TotalKWh += costed.KWh
+		report.EnergyCost += costed.EnergyCost
+		addBreakdown(appliances, costed.Appliance, costed.KWh, costed.Cost)
+		addBreakdown(rooms, costed.Room, costed.KWh, costed.Cost)
+		addBreakdown(months, costed.Timestamp.Format("2006-01"), costed.KWh, costed.Cost)
+	}
+
+	if len(report.Records) > 0 {
+		start, end := history[0].Timestamp, history[len(history)-1].Timestamp.Add(readingInterval)
+		if from.After(start) {
+			start = from
+		}
+		if !to.IsZero() && to.Before(end) {
+			end = to
+		}
+		for month, share := range monthShares(start, end) {
+			fixed := tariff.FixedMonthly * share
+			report.FixedCharges += fixed
+			addBreakdown(months, month, 0, fixed*(1+taxRate))
+		}
+	}
+	report.Tax = (report.EnergyCost + report.FixedCharges) * taxRate
+	report.TotalCost = report.EnergyCost + report.FixedCharges + report.Tax
+
+	report.TotalKWh = roundKWh(report.TotalKWh)
+	report.EnergyCost = roundMoney(report.EnergyCost)
+	report.FixedCharges = roundMoney(report.FixedCharges)
+	report.Tax = roundMoney(report.Tax)
+	report.TotalCost = roundMoney(report.TotalCost)
+	report.ByAppliance = sortedBreakdown(appliances, true)
+	report.ByRoom = sortedBreakdown(rooms, true)
+	report.ByMonth = sortedBreakdown(months, false)
+
+	if len(report.Records) > 0 {
+		report.From = report.Records[0].Timestamp
+		report.To = report.Records[len(report.Records)-1].Timestamp.Add(readingInterval)
+	}
+	return report
+}
+
+// costRecords prices each record of a time-ordered history. Tiered blocks
+// restart every calendar month.
+func costRecords(tariff model.Tariff, history []model.EnergyRecord) []model.CostedRecord {
+	taxRate := tariff.TaxPercent / 100
+	monthUsage := map[string]float64{}
+	costed := make([]model.CostedRecord, 0, len(history))
+
+	for _, record := range history {
+		month := record.Timestamp.Format("2006-01")
+
+		var energyCost float64
+		switch tariff.Type {
+		case model.TariffTimeOfUse:
+			energyCost = record.KWh * bandRate(tariff, record.Timestamp)
+		case model.TariffTiered:
+			energyCost = tieredCost(tariff.Tiers, monthUsage[month], record.KWh)
+		default:
+			energyCost = record.KWh * tariff.FlatRate
+		}
+		monthUsage[month] += record.KWh
+
+		item := model.CostedRecord{
+			Timestamp:  record.Timestamp,
+			Appliance:  record.Appliance,
+			Room:       record.Room,
+			KWh:        record.KWh,
+			EnergyCost: roundMoney(energyCost),
+			Cost:       roundMoney(energyCost * (1 + taxRate)),
+		}
+		if record.KWh > 0 {
+			item.Rate = roundMoney(energyCost / record.KWh)
+		}
+		costed = append(costed, item)
+	}
+	return costed
+}
+
+// monthShares returns, per calendar month, the fraction of it that
+// [start, end) covers.
+func monthShares(start, end time.Time) map[string]float64 {
+	shares := map[string]float64{}
+	for month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, start.Location()); month.Before(end); month = month.AddDate(0, 1, 0) {
+		next := month.AddDate(0, 1, 0)
+		overlapStart, overlapEnd := month, next
+		if start.After(overlapStart) {
+			overlapStart = start
+		}
+		if end.Before(overlapEnd) {
+			overlapEnd = end
+		}
+		if overlapEnd.After(overlapStart) {
+			shares[month.Format("2006-01")] = overlapEnd.Sub(overlapStart).Hours() / next.Sub(month).Hours()
+		}
+	}
+	return shares
+}
+
+func validateTariff(tariff model.Tariff) error {
+	if tariff.TaxPercent < 0 || tariff.TaxPercent > 100 {
+		return fmt.Errorf("%w: tax_percent must be between 0 and 100", ErrInvalidTariff)
+	}
+	if tariff.FixedMonthly < 0 || tariff.FlatRate < 0 {
+		return fmt.Errorf("%w: rates and charges cannot be negative", ErrInvalidTariff)
+	}
+
+	switch tariff.Type {
+	case model.TariffFlat:
+		if tariff.FlatRate == 0 {
+			return fmt.Errorf("%w: flat_rate is required for a flat tariff", ErrInvalidTariff)
+		}
+	case model.TariffTimeOfUse:
+		if len(tariff.Bands) == 0 {
+			return fmt.Errorf("%w: a time_of_use tariff needs at least one band", ErrInvalidTariff)
+		}
+		for _, band := range tariff.Bands {
+			_, startErr := clockMinutes(band.Start)
+			_, endErr := clockMinutes(band.End)
+			if startErr != nil || endErr != nil {
+				return fmt.Errorf("%w: band %q needs start and end as HH:MM", ErrInvalidTariff, band.Name)
+			}
+			if band.Rate < 0 {
+				return fmt.Errorf("%w: band %q has a negative rate", ErrInvalidTariff, band.Name)
+			}
+		}
+		if tariff.FlatRate == 0 && !bandsCoverDay(tariff.Bands) {
+			return fmt.Errorf("%w: time_of_use bands must cover all 24 hours unless flat_rate prices the hours outside them", ErrInvalidTariff)
+		}
+	case model.TariffTiered:
+		if len(tariff.Tiers) == 0 {
+			return fmt.Errorf("%w: a tiered tariff needs at least one tier", ErrInvalidTariff)
+		}
+		previous := 0.0
+		for i, tier := range tariff.Tiers {
+			if tier.Rate < 0 {
+				return fmt.Errorf("%w: tier %d has a negative rate", ErrInvalidTariff, i+1)
+			}
+			if tier.UpToKWh == nil {
+				if i != len(tariff.Tiers)-1 {
+					return fmt.Errorf("%w: only the last tier may be unbounded", ErrInvalidTariff)
+				}
+				continue
+			}
+			if *tier.UpToKWh <= previous {
+				return fmt.Errorf("%w: tier limits must be increasing", ErrInvalidTariff)
+			}
+			previous = *tier.UpToKWh
+		}
+	default:
+		return fmt.Errorf("%w: type must be flat, time_of_use or tiered", ErrInvalidTariff)
+	}
+	return nil
+}
+
+// bandRate returns the rate of the first band covering the record's time,
+// falling back to the flat rate outside every band.
+func bandRate(tariff model.Tariff, timestamp time.Time) float64 {
+	minute := timestamp.Hour()*60 + timestamp.Minute()
+	for _, band := range tariff.Bands {
+		start, _ := clockMinutes(band.Start)
+		end, _ := clockMinutes(band.End)
+		if start <= end && minute >= start && minute < end {
+			return band.Rate
+		}
+		if start > end && (minute >= start || minute < end) {
+			return band.Rate
+		}
+	}
+	return tariff.FlatRate
+}
+
+// bandsCoverDay reports whether every minute of the day falls in some band,
+// matched the same way as bandRate.
+func bandsCoverDay(bands []model.TariffBand) bool {
+	var covered [24 * 60]bool
+	for _, band := range bands {
+		start, _ := clockMinutes(band.Start)
+		end, _ := clockMinutes(band.End)
+		for minute := range covered {
+			if (start <= end && minute >= start && minute < end) || (start > end && (minute >= start || minute < end)) {
+				covered[minute] = true
+			}
+		}
+	}
+	for _, ok := range covered {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// tieredCost prices kwh consumed after used kWh earlier in the month, split
+// across the blocks it spans. Usage beyond a bounded last tier stays at its rate.
+func tieredCost(tiers []model.TariffTier, used, kwh float64) float64 {
+	cost := 0.0
+	lower := 0.0
+	for i, tier := range tiers {
+		upper := math.Inf(1)
+		if tier.UpToKWh != nil && i != len(tiers)-1 {
+			upper = *tier.UpToKWh
+		}
+		if used < upper {
+			portion := math.Min(used+kwh, upper) - math.Max(used, lower)
+			if portion > 0 {
+				cost += portion * tier.Rate
+			}
+		}
+		lower = upper
+	}
+	return cost
+}
+
+func clockMinutes(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		if value == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func describeTariff(tariff model.Tariff) string {
+	var description string
+	switch tariff.Type {
+	case model.TariffTimeOfUse:
+		bands := make([]string, 0, len(tariff.Bands))
+		for _, band := range tariff.Bands {
+			bands = append(bands, fmt.Sprintf("%s %s-%s %.2f/kWh", band.Name, band.Start, band.End, band.Rate))
+		}
+		description = fmt.Sprintf("%s, waktu pemakaian (%s; di luar itu %.2f/kWh)", tariff.Name, strings.Join(bands, ", "), tariff.FlatRate)
+	case model.TariffTiered:
+		tiers := make([]string, 0, len(tariff.Tiers))
+		for _, tier := range tariff.Tiers {
+			if tier.UpToKWh == nil {
+				tiers = append(tiers, fmt.Sprintf("selebihnya %.2f/kWh", tier.Rate))
+			} else {
+				tiers = append(tiers, fmt.Sprintf("sampai %g kWh %.2f/kWh", *tier.UpToKWh, tier.Rate))
+			}
+		}
+		description = fmt.Sprintf("%s, blok bulanan (%s)", tariff.Name, strings.Join(tiers, ", "))
+	default:
+		description = fmt.Sprintf("%s, %.2f/kWh", tariff.Name, tariff.FlatRate)
+	}
+
+	description += " dalam " + tariff.Currency
+	if tariff.TaxPercent > 0 {
+		description += fmt.Sprintf(", pajak %g%%", tariff.TaxPercent)
+	}
+	if tariff.FixedMonthly > 0 {
+		description += fmt.Sprintf(", biaya tetap %.2f per bulan", tariff.FixedMonthly)
+	}
+	return strings.TrimPrefix(description, ", ")
+}
+
+func addBreakdown(breakdowns map[string]*model.CostBreakdown, key string, kwh, cost float64) {
+	if key == "" {
+		key = "Unknown"
+	}
+	if breakdowns[key] == nil {
+		breakdowns[key] = &model.CostBreakdown{Key: key}
+	}
+	breakdowns[key].KWh += kwh
+	breakdowns[key].Cost += cost
+}
+
+// sortedBreakdown orders by cost, most expensive first, or by key.
+func sortedBreakdown(breakdowns map[string]*model.CostBreakdown, byCost bool) []model.CostBreakdown {
+	sorted := make([]model.CostBreakdown, 0, len(breakdowns))
+	for _, breakdown := range breakdowns {
+		sorted = append(sorted, model.CostBreakdown{
+			Key:  breakdown.Key,
+			KWh:  roundKWh(breakdown.KWh),
+			Cost: roundMoney(breakdown.Cost),
+		})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if byCost && sorted[i].Cost != sorted[j].Cost {
+			return sorted[i].Cost > sorted[j].Cost
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+	return sorted
+}
+
+func roundMoney(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"luma-backend/model"
+)
+
+func kwhLimit(value float64) *float64 {
+	return &value
+}
+
+// aprilHistory has one 10 kWh reading at 23:00 on every day of April 2023,
+// so the readings cover the month up to midnight on May 1.
+func aprilHistory() []model.EnergyRecord {
+	records := make([]model.EnergyRecord, 0, 30)
+	for day := 1; day <= 30; day++ {
+		records = append(records, model.EnergyRecord{
+			Timestamp: time.Date(2023, time.April, day, 23, 0, 0, 0, time.UTC),
+			Appliance: "Heater",
+			Room:      "Bedroom",
+			KWh:       10,
+		})
+	}
+	return records
+}
+
+func TestPriceRecordsBetweenKeepsTieredPricesIndependentOfWindow(t *testing.T) {
+	tariff := model.Tariff{
+		Type:     model.TariffTiered,
+		Currency: "IDR",
+		Tiers:    []model.TariffTier{{UpToKWh: kwhLimit(100), Rate: 1}, {Rate: 10}},
+	}
+	history := aprilHistory()
+	from := time.Date(2023, time.April, 21, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	window := PriceRecordsBetween(tariff, history, from, to)
+	if window.EnergyCost != 1000 {
+		t.Errorf("days 21-30 cost %v, want 1000 since the first 100 kWh of April were used by day 10", window.EnergyCost)
+	}
+
+	full := PriceRecords(tariff, history)
+	if full.EnergyCost != 100+200*10 {
+		t.Errorf("April cost %v, want %v", full.EnergyCost, 100+200*10)
+	}
+	for _, costed := range full.Records {
+		if !costed.Timestamp.Before(from) && costed.EnergyCost != 100 {
+			t.Errorf("%s priced at %v in the full month, want 100", costed.Timestamp, costed.EnergyCost)
+		}
+	}
+}
+
+func TestPriceRecordsBetweenProRatesFixedCharges(t *testing.T) {
+	tariff := model.Tariff{Type: model.TariffFlat, Currency: "IDR", FlatRate: 1, FixedMonthly: 3000, TaxPercent: 10}
+	history := aprilHistory()
+
+	tests := []struct {
+		name      string
+		from, to  time.Time
+		wantFixed float64
+	}{
+		{"last ten days", time.Date(2023, time.April, 21, 0, 0, 0, 0, time.UTC), time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC), 1000},
+		{"one reading", time.Date(2023, time.April, 10, 23, 0, 0, 0, time.UTC), time.Date(2023, time.April, 11, 0, 0, 0, 0, time.UTC), 3000.0 / (30 * 24)},
+		{"window past the data", time.Date(2023, time.April, 26, 0, 0, 0, 0, time.UTC), time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC), 500},
+		{"whole history", time.Time{}, time.Time{}, 3000 * (29*24 + 1) / (30 * 24.0)},
+		{"no readings", time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC), 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := PriceRecordsBetween(tariff, history, test.from, test.to)
+			if report.FixedCharges != roundMoney(test.wantFixed) {
+				t.Errorf("fixed charges = %v, want %v", report.FixedCharges, roundMoney(test.wantFixed))
+			}
+			wantTotal := roundMoney((report.EnergyCost + report.FixedCharges) * 1.1)
+			if math.Abs(report.TotalCost-wantTotal) > 0.015 {
+				t.Errorf("total = %v, want %v", report.TotalCost, wantTotal)
+			}
+		})
+	}
+}
+
+func TestPriceRecordsBetweenSpansReadings(t *testing.T) {
+	tariff := model.Tariff{Type: model.TariffFlat, FlatRate: 1}
+	history := aprilHistory()
+	day := func(day, hour int) time.Time {
+		return time.Date(2023, time.April, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name             string
+		from, to         time.Time
+		wantFrom, wantTo time.Time
+	}{
+		{"whole history", time.Time{}, time.Time{}, day(1, 23), day(30, 24)},
+		{"window inside the data", day(10, 0), day(20, 0), day(10, 23), day(20, 0)},
+		{"window past the data", day(26, 0), time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC), day(26, 23), day(30, 24)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := PriceRecordsBetween(tariff, history, test.from, test.to)
+			if !report.From.Equal(test.wantFrom) || !report.To.Equal(test.wantTo) {
+				t.Errorf("report spans %s to %s, want %s to %s", report.From, report.To, test.wantFrom, test.wantTo)
+			}
+		})
+	}
+}
+
+func TestTieredCost(t *testing.T) {
+	tiers := []model.TariffTier{{UpToKWh: kwhLimit(100), Rate: 1}, {UpToKWh: kwhLimit(200), Rate: 2}, {Rate: 5}}
+
+	tests := []struct {
+		name      string
+		tiers     []model.TariffTier
+		used, kwh float64
+		want      float64
+	}{
+		{"inside the first block", tiers, 0, 50, 50},
+		{"fills the first block exactly", tiers, 50, 50, 50},
+		{"spans two blocks", tiers, 90, 20, 10*1 + 10*2},
+		{"spans every block", tiers, 50, 250, 50*1 + 100*2 + 100*5},
+		{"beyond the last limit", tiers, 300, 10, 50},
+		{"nothing used", tiers, 120, 0, 0},
+		{"bounded last tier keeps its rate", []model.TariffTier{{UpToKWh: kwhLimit(100), Rate: 1}, {UpToKWh: kwhLimit(200), Rate: 3}}, 150, 100, 300},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := tieredCost(test.tiers, test.used, test.kwh); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("tieredCost(%v, %v) = %v, want %v", test.used, test.kwh, got, test.want)
+			}
+		})
+	}
+}
+
+func TestBandRate(t *testing.T) {
+	tariff := model.Tariff{
+		Type:     model.TariffTimeOfUse,
+		FlatRate: 1000,
+		Bands: []model.TariffBand{
+			{Name: "peak", Start: "17:00", End: "22:00", Rate: 2000},
+			{Name: "night", Start: "22:00", End: "06:00", Rate: 500},
+		},
+	}
+
+	tests := []struct {
+		clock string
+		want  float64
+	}{
+		{"17:00", 2000},
+		{"21:59", 2000},
+		{"22:00", 500},
+		{"00:00", 500},
+		{"05:59", 500},
+		{"06:00", 1000},
+		{"12:00", 1000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.clock, func(t *testing.T) {
+			clock, _ := time.Parse("15:04", test.clock)
+			timestamp := time.Date(2023, time.April, 1, clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+			if got := bandRate(tariff, timestamp); got != test.want {
+				t.Errorf("bandRate at %s = %v, want %v", test.clock, got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateTariff(t *testing.T) {
+	tests := []struct {
+		name   string
+		tariff model.Tariff
+		valid  bool
+	}{
+		{"flat", model.Tariff{Type: model.TariffFlat, FlatRate: 1444.7}, true},
+		{"flat without a rate", model.Tariff{Type: model.TariffFlat}, false},
+		{"negative fixed charge", model.Tariff{Type: model.TariffFlat, FlatRate: 1, FixedMonthly: -1}, false},
+		{"tax above 100%", model.Tariff{Type: model.TariffFlat, FlatRate: 1, TaxPercent: 120}, false},
+		{"time of use", model.Tariff{Type: model.TariffTimeOfUse, FlatRate: 1, Bands: []model.TariffBand{{Name: "peak", Start: "17:00", End: "24:00", Rate: 2}}}, true},
+		{"time of use covering the day", model.Tariff{Type: model.TariffTimeOfUse, Bands: []model.TariffBand{{Name: "peak", Start: "17:00", End: "22:00", Rate: 2}, {Name: "off-peak", Start: "22:00", End: "17:00", Rate: 1}}}, true},
+		{"time of use with a gap and no flat rate", model.Tariff{Type: model.TariffTimeOfUse, Bands: []model.TariffBand{{Name: "peak", Start: "17:00", End: "22:00", Rate: 2}, {Name: "night", Start: "22:00", End: "06:00", Rate: 1}}}, false},
+		{"time of use with an empty band and no flat rate", model.Tariff{Type: model.TariffTimeOfUse, Bands: []model.TariffBand{{Name: "all day", Start: "00:00", End: "00:00", Rate: 1}}}, false},
+		{"time of use without bands", model.Tariff{Type: model.TariffTimeOfUse}, false},
+		{"band with a bad clock", model.Tariff{Type: model.TariffTimeOfUse, Bands: []model.TariffBand{{Name: "peak", Start: "5pm", End: "22:00", Rate: 2}}}, false},
+		{"band with a negative rate", model.Tariff{Type: model.TariffTimeOfUse, Bands: []model.TariffBand{{Name: "peak", Start: "17:00", End: "22:00", Rate: -2}}}, false},
+		{"tiered", model.Tariff{Type: model.TariffTiered, Tiers: []model.TariffTier{{UpToKWh: kwhLimit(100), Rate: 1}, {Rate: 2}}}, true},
+		{"tiered without tiers", model.Tariff{Type: model.TariffTiered}, false},
+		{"unbounded tier before the last", model.Tariff{Type: model.TariffTiered, Tiers: []model.TariffTier{{Rate: 1}, {UpToKWh: kwhLimit(100), Rate: 2}}}, false},
+		{"decreasing tier limits", model.Tariff{Type: model.TariffTiered, Tiers: []model.TariffTier{{UpToKWh: kwhLimit(200), Rate: 1}, {UpToKWh: kwhLimit(100), Rate: 2}}}, false},
+		{"unknown type", model.Tariff{Type: "seasonal", FlatRate: 1}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateTariff(test.tariff)
+			if test.valid && err != nil {
+				t.Errorf("validateTariff = %v, want valid", err)
+			}
+			if !test.valid && !errors.Is(err, ErrInvalidTariff) {
+				t.Errorf("validateTariff = %v, want ErrInvalidTariff", err)
+			}
+		})
+	}
+}
+
+func TestPriceRecords(t *testing.T) {
+	history := []model.EnergyRecord{
+		{Timestamp: time.Date(2023, time.April, 30, 18, 0, 0, 0, time.UTC), Appliance: "TV", Room: "Living Room", KWh: 1},
+		{Timestamp: time.Date(2023, time.April, 30, 23, 0, 0, 0, time.UTC), Appliance: "Heater", Room: "Bedroom", KWh: 2},
+		{Timestamp: time.Date(2023, time.May, 1, 12, 0, 0, 0, time.UTC), Appliance: "Heater", Room: "Bedroom", KWh: 3},
+	}
+
+	tests := []struct {
+		name          string
+		tariff        model.Tariff
+		wantEnergy    float64
+		wantAppliance []string
+		wantRates     []float64
+	}{
+		{
+			name:          "flat",
+			tariff:        model.Tariff{Type: model.TariffFlat, FlatRate: 100},
+			wantEnergy:    600,
+			wantAppliance: []string{"Heater", "TV"},
+			wantRates:     []float64{100, 100, 100},
+		},
+		{
+			name: "time of use",
+			tariff: model.Tariff{Type: model.TariffTimeOfUse, FlatRate: 100, Bands: []model.TariffBand{
+				{Name: "peak", Start: "17:00", End: "22:00", Rate: 300},
+				{Name: "night", Start: "22:00", End: "06:00", Rate: 50},
+			}},
+			wantEnergy:    300 + 2*50 + 3*100,
+			wantAppliance: []string{"Heater", "TV"},
+			wantRates:     []float64{300, 50, 100},
+		},
+		{
+			name:          "tiered blocks restart each month",
+			tariff:        model.Tariff{Type: model.TariffTiered, Tiers: []model.TariffTier{{UpToKWh: kwhLimit(2), Rate: 100}, {Rate: 1000}}},
+			wantEnergy:    100 + (100 + 1000) + (2*100 + 1000),
+			wantAppliance: []string{"Heater", "TV"},
+			wantRates:     []float64{100, 550, 400},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.tariff.TaxPercent = 10
+			report := PriceRecords(test.tariff, history)
+
+			if report.EnergyCost != test.wantEnergy {
+				t.Errorf("energy cost = %v, want %v", report.EnergyCost, test.wantEnergy)
+			}
+			if report.Tax != roundMoney(test.wantEnergy*0.1) || report.TotalCost != roundMoney(test.wantEnergy*1.1) {
+				t.Errorf("tax and total = %v and %v, want %v and %v", report.Tax, report.TotalCost, test.wantEnergy*0.1, test.wantEnergy*1.1)
+			}
+			if report.TotalKWh != 6 || len(report.Records) != len(history) {
+				t.Errorf("priced %v kWh in %d records, want 6 kWh in %d", report.TotalKWh, len(report.Records), len(history))
+			}
+			for i, costed := range report.Records {
+				if costed.Rate != test.wantRates[i] {
+					t.Errorf("record %d rate = %v, want %v", i, costed.Rate, test.wantRates[i])
+				}
+			}
+			for i, key := range test.wantAppliance {
+				if report.ByAppliance[i].Key != key {
+					t.Errorf("appliance %d = %s, want %s", i, report.ByAppliance[i].Key, key)
+				}
+			}
+			if len(report.ByMonth) != 2 || report.ByMonth[0].Key != "2023-04" || report.ByMonth[1].Key != "2023-05" {
+				t.Errorf("months = %+v, want April then May", report.ByMonth)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"luma-backend/model"
+	"luma-backend/repository"
+)
+
+var (
+	ErrDatasetNotFound = errors.New("dataset not found")
+	ErrInvalidDataset  = errors.New("invalid dataset")
+)
+
+type DatasetService struct {
+	DatasetRepo *repository.DatasetRepository
+}
+
+func (s *DatasetService) UploadDataset(email, householdID, name, data string) (model.Dataset, model.IngestReport, []model.RowError, error) {
+	table, report, err := repository.ParseCSV(data)
+	if err != nil {
+		return model.Dataset{}, report, nil, fmt.Errorf("%w: %v", ErrInvalidDataset, err)
+	}
+
+	schema := repository.InferSchema(table, report.Delimiter == ";")
+	typed, rowErrors := repository.TypeTable(table, schema)
+
+	dataset := model.Dataset{
+		Email:       email,
+		HouseholdID: householdID,
+		Name:        name,
+		Table:       table,
+		Schema:      schema,
+		Rows:        len(typed.Rows),
+		UploadedAt:  time.Now(),
+	}
+
+	err = s.DatasetRepo.SaveDataset(dataset)
+	if err != nil {
+		return model.Dataset{}, report, nil, err
+	}
+
+	return dataset, report, rowErrors, nil
+}
+
+func (s *DatasetService) GetDataset(email, householdID string) (model.Dataset, error) {
+	dataset, err := s.DatasetRepo.GetDataset(email, householdID)
+	if err != nil {
+		return model.Dataset{}, err
+	}
+	if dataset == nil {
+		return model.Dataset{}, ErrDatasetNotFound
+	}
+	if len(dataset.Schema.Columns) == 0 {
+		dataset.Schema = repository.InferSchema(dataset.Table, false)
+	}
+	return *dataset, nil
+}
+
+func (s *DatasetService) GetTable(email, householdID string) (map[string][]string, error) {
+	dataset, err := s.GetDataset(email, householdID)
+	if err != nil {
+		return nil, err
+	}
+	return dataset.Table, nil
+}
+
+func (s *DatasetService) GetTypedTable(email, householdID string) (model.TypedTable, error) {
+	dataset, err := s.GetDataset(email, householdID)
+	if err != nil {
+		return model.TypedTable{}, err
+	}
+	return TypedTable(dataset), nil
+}
+
+func TypedTable(dataset model.Dataset) model.TypedTable {
+	typed, _ := repository.TypeTable(dataset.Table, dataset.Schema)
+	return typed
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"luma-backend/model"
+)
+
+const maxSeriesBuckets = 5000
+
+// readingInterval is how long one reading of the energy export covers.
+const readingInterval = time.Hour
+
+var (
+	ErrNoEnergyData       = errors.New("dataset has no Date and Energy_Consumption columns")
+	ErrInvalidSeriesQuery = errors.New("invalid series query")
+)
+
+type SeriesQuery struct {
+	GroupBy  string
+	Interval string
+	From     time.Time
+	To       time.Time
+}
+
+type EnergyService struct {
+	Datasets *DatasetService
+}
+
+func (s *EnergyService) Records(email, householdID string) ([]model.EnergyRecord, error) {
+	dataset, err := s.Datasets.GetDataset(email, householdID)
+	if err != nil {
+		return nil, err
+	}
+	return EnergyRecords(TypedTable(dataset))
+}
+
+// Series totals kWh per appliance or room into hour, day or week buckets.
+// Every series has a point for every bucket in [From, To), zero where
+// nothing was recorded, so the dashboard can stack them directly.
+func (s *EnergyService) Series(email, householdID string, query SeriesQuery) (model.EnergySeries, error) {
+	if query.GroupBy == "" {
+		query.GroupBy = "appliance"
+	}
+	if query.Interval == "" {
+		query.Interval = "day"
+	}
+	if query.GroupBy != "appliance" && query.GroupBy != "room" {
+		return model.EnergySeries{}, fmt.Errorf("%w: group_by must be appliance or room", ErrInvalidSeriesQuery)
+	}
+	if query.Interval != "hour" && query.Interval != "day" && query.Interval != "week" {
+		return model.EnergySeries{}, fmt.Errorf("%w: interval must be hour, day or week", ErrInvalidSeriesQuery)
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return model.EnergySeries{}, fmt.Errorf("%w: from must be before to", ErrInvalidSeriesQuery)
+	}
+
+	records, err := s.Records(email, householdID)
+	if err != nil {
+		return model.EnergySeries{}, err
+	}
+	return SeriesFromRecords(records, query)
+}
+
+// SeriesFromRecords buckets records for an already validated query.
+func SeriesFromRecords(records []model.EnergyRecord, query SeriesQuery) (model.EnergySeries, error) {
+	records = recordsBetween(records, query.From, query.To)
+
+	result := model.EnergySeries{
+		GroupBy:  query.GroupBy,
+		Interval: query.Interval,
+		From:     query.From,
+		To:       query.To,
+		Unit:     "kWh",
+		Series:   []model.Series{},
+	}
+	if len(records) == 0 {
+		return result, nil
+	}
+	if result.From.IsZero() {
+		result.From = records[0].Timestamp
+	}
+	if result.To.IsZero() {
+		result.To = records[len(records)-1].Timestamp.Add(time.Nanosecond)
+	}
+	result.From = bucketStart(result.From, query.Interval)
+
+	var buckets []time.Time
+	index := map[int64]int{}
+	for start := result.From; start.Before(result.To); start = nextBucket(start, query.Interval) {
+		if len(buckets) == maxSeriesBuckets {
+			return model.EnergySeries{}, fmt.Errorf("%w: more than %d %s buckets; narrow from/to or use a longer interval", ErrInvalidSeriesQuery, maxSeriesBuckets, query.Interval)
+		}
+		index[start.Unix()] = len(buckets)
+		buckets = append(buckets, start)
+	}
+	result.To = nextBucket(buckets[len(buckets)-1], query.Interval)
+
+	totals := map[string][]float64{}
+	for _, record := range records {
+		key := record.Appliance
+		if query.GroupBy == "room" {
+			key = record.Room
+		}
+		if key == "" {
+			key = "Unknown"
+		}
+		if totals[key] == nil {
+			totals[key] = make([]float64, len(buckets))
+		}
+		totals[key][index[bucketStart(record.Timestamp, query.Interval).Unix()]] += record.KWh
+	}
+
+	for key, values := range totals {
+		series := model.Series{Key: key, Points: make([]model.SeriesPoint, len(buckets))}
+		for i, value := range values {
+			series.Points[i] = model.SeriesPoint{Start: buckets[i], KWh: roundKWh(value)}
+			series.TotalKWh += value
+		}
+		result.TotalKWh += series.TotalKWh
+		series.TotalKWh = roundKWh(series.TotalKWh)
+		result.Series = append(result.Series, series)
+	}
+	result.TotalKWh = roundKWh(result.TotalKWh)
+
+	sort.Slice(result.Series, func(i, j int) bool {
+		if result.Series[i].TotalKWh != result.Series[j].TotalKWh {
+			return result.Series[i].TotalKWh > result.Series[j].TotalKWh
+		}
+		return result.Series[i].Key < result.Series[j].Key
+	})
+	return result, nil
+}
+
+// EnergyRecords reads the energy export columns into timestamped records,
+// oldest first. Rows without a valid date or consumption are skipped; the
+// upload report has already told the user about them.
+func EnergyRecords(table model.TypedTable) ([]model.EnergyRecord, error) {
+	schema := table.Schema
+	dateIndex := schema.ColumnIndex(model.ColumnNameDate)
+	energyIndex := schema.ColumnIndex(model.ColumnNameEnergy)
+	if dateIndex < 0 || energyIndex < 0 {
+		return nil, ErrNoEnergyData
+	}
+	timeIndex := schema.ColumnIndex(model.ColumnNameTime)
+
+	records := make([]model.EnergyRecord, 0, len(table.Rows))
+	for _, row := range table.Rows {
+		date := row[dateIndex]
+		kwh, ok := numberAt(schema, row, energyIndex)
+		if !date.Valid || !ok || schema.Columns[dateIndex].Type != model.ColumnDate {
+			continue
+		}
+
+		timestamp := date.Time
+		if timeIndex >= 0 && row[timeIndex].Valid && schema.Columns[timeIndex].Type == model.ColumnTime {
+			clock := row[timeIndex].Time
+			timestamp = timestamp.Add(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute)
+		}
+
+		record := model.EnergyRecord{
+			Timestamp: timestamp,
+			Appliance: textAt(schema, row, model.ColumnNameAppliance),
+			Room:      textAt(schema, row, model.ColumnNameRoom),
+			Status:    textAt(schema, row, model.ColumnNameStatus),
+			Season:    textAt(schema, row, model.ColumnNameSeason),
+			KWh:       kwh,
+		}
+		if value, ok := numberAt(schema, row, schema.ColumnIndex(model.ColumnNameTemperature)); ok {
+			record.Temperature = &value
+		}
+		if value, ok := numberAt(schema, row, schema.ColumnIndex(model.ColumnNamePeople)); ok {
+			record.People = &value
+		}
+		records = append(records, record)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+	return records, nil
+}
+
+func recordsBetween(records []model.EnergyRecord, from, to time.Time) []model.EnergyRecord {
+	filtered := make([]model.EnergyRecord, 0, len(records))
+	for _, record := range records {
+		if !from.IsZero() && record.Timestamp.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !record.Timestamp.Before(to) {
+			continue
+		}
+		filtered = append(filtered, record)
+	}
+	return filtered
+}
+
+func numberAt(schema model.Schema, row []model.Value, index int) (float64, bool) {
+	if index < 0 || !row[index].Valid {
+		return 0, false
+	}
+	switch schema.Columns[index].Type {
+	case model.ColumnFloat, model.ColumnInt:
+		return row[index].Number, true
+	}
+	return 0, false
+}
+
+func textAt(schema model.Schema, row []model.Value, name string) string {
+	index := schema.ColumnIndex(name)
+	if index < 0 {
+		return ""
+	}
+	return row[index].Raw
+}
+
+// bucketStart truncates t to the start of its hour, day or ISO week (Monday).
+func bucketStart(t time.Time, interval string) time.Time {
+	switch interval {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
+
+func nextBucket(t time.Time, interval string) time.Time {
+	switch interval {
+	case "hour":
+		return t.Add(time.Hour)
+	case "week":
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+func roundKWh(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"luma-backend/model"
+	"luma-backend/repository"
+)
+
+func at(day, hour int) time.Time {
+	return time.Date(2023, time.January, day, hour, 0, 0, 0, time.UTC)
+}
+
+func testDataset(t *testing.T, data string) model.Dataset {
+	t.Helper()
+	table, report, err := repository.ParseCSV(data)
+	if err != nil {
+		t.Fatalf("ParseCSV: %v", err)
+	}
+	return model.Dataset{
+		Email:  "a@example.com",
+		Table:  table,
+		Schema: repository.InferSchema(table, report.Delimiter == ";"),
+	}
+}
+
+func TestSeriesFromRecords(t *testing.T) {
+	records := []model.EnergyRecord{
+		{Timestamp: at(2, 8), Appliance: "TV", Room: "Living Room", KWh: 0.5},
+		{Timestamp: at(2, 20), Appliance: "TV", Room: "Living Room", KWh: 0.25},
+		{Timestamp: at(2, 21), Appliance: "Heater", Room: "Bedroom", KWh: 1.5},
+		{Timestamp: at(4, 9), Appliance: "Heater", Room: "Bedroom", KWh: 2},
+		{Timestamp: at(4, 10), Appliance: "", Room: "", KWh: 0.1},
+	}
+
+	tests := []struct {
+		name       string
+		query      SeriesQuery
+		wantFrom   time.Time
+		wantTo     time.Time
+		wantPoints int
+		wantTotals map[string]float64
+		wantFirst  string
+	}{
+		{
+			name:       "daily by appliance fills empty days",
+			query:      SeriesQuery{GroupBy: "appliance", Interval: "day"},
+			wantFrom:   at(2, 0),
+			wantTo:     at(5, 0),
+			wantPoints: 3,
+			wantTotals: map[string]float64{"Heater": 3.5, "TV": 0.75, "Unknown": 0.1},
+			wantFirst:  "Heater",
+		},
+		{
+			name:       "hourly by room within a window",
+			query:      SeriesQuery{GroupBy: "room", Interval: "hour", From: at(2, 20), To: at(2, 22)},
+			wantFrom:   at(2, 20),
+			wantTo:     at(2, 22),
+			wantPoints: 2,
+			wantTotals: map[string]float64{"Bedroom": 1.5, "Living Room": 0.25},
+			wantFirst:  "Bedroom",
+		},
+		{
+			name:       "weekly buckets start on Monday",
+			query:      SeriesQuery{GroupBy: "appliance", Interval: "week", From: at(4, 0)},
+			wantFrom:   at(2, 0),
+			wantTo:     at(9, 0),
+			wantPoints: 1,
+			wantTotals: map[string]float64{"Heater": 2, "Unknown": 0.1},
+			wantFirst:  "Heater",
+		},
+		{
+			name:       "window without readings",
+			query:      SeriesQuery{GroupBy: "appliance", Interval: "day", From: at(10, 0), To: at(11, 0)},
+			wantFrom:   at(10, 0),
+			wantTo:     at(11, 0),
+			wantTotals: map[string]float64{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			series, err := SeriesFromRecords(records, test.query)
+			if err != nil {
+				t.Fatalf("SeriesFromRecords: %v", err)
+			}
+			if !series.From.Equal(test.wantFrom) || !series.To.Equal(test.wantTo) {
+				t.Errorf("range = %s to %s, want %s to %s", series.From, series.To, test.wantFrom, test.wantTo)
+			}
+			if len(series.Series) != len(test.wantTotals) {
+				t.Fatalf("got %d series, want %d", len(series.Series), len(test.wantTotals))
+			}
+			for _, item := range series.Series {
+				if item.TotalKWh != test.wantTotals[item.Key] {
+					t.Errorf("%s total = %v, want %v", item.Key, item.TotalKWh, test.wantTotals[item.Key])
+				}
+				if len(item.Points) != test.wantPoints {
+					t.Errorf("%s has %d points, want %d", item.Key, len(item.Points), test.wantPoints)
+				}
+				sum := 0.0
+				for _, point := range item.Points {
+					sum += point.KWh
+				}
+				if roundKWh(sum) != item.TotalKWh {
+					t.Errorf("%s points sum to %v, total says %v", item.Key, sum, item.TotalKWh)
+				}
+			}
+			if test.wantFirst != "" && series.Series[0].Key != test.wantFirst {
+				t.Errorf("first series = %s, want the largest, %s", series.Series[0].Key, test.wantFirst)
+			}
+		})
+	}
+}
+
+func TestSeriesFromRecordsCapsBuckets(t *testing.T) {
+	records := []model.EnergyRecord{{Timestamp: at(1, 0), Appliance: "TV", KWh: 1}}
+	_, err := SeriesFromRecords(records, SeriesQuery{GroupBy: "appliance", Interval: "hour", To: at(1, 0).Add((maxSeriesBuckets + 1) * time.Hour)})
+	if !errors.Is(err, ErrInvalidSeriesQuery) {
+		t.Errorf("err = %v, want ErrInvalidSeriesQuery", err)
+	}
+}
+
+func TestEnergyRecords(t *testing.T) {
+	data := "Date,Time,Appliance,Room,Energy_Consumption,Status,Temperature,Number_of_People\n" +
+		"2023-01-01,13:30,Heater,Bedroom,1.5,On,18,2\n" +
+		"2023-01-01,08:00,TV,Living Room,0.8,Off,,3\n" +
+		"2023-01-02,09:00,TV,Living Room,,On,20,3\n"
+	for hour := 0; hour < 10; hour++ {
+		data += fmt.Sprintf("2023-01-03,%02d:00,Light,Kitchen,0.1,On,21,3\n", hour)
+	}
+	data += "yesterday,09:00,TV,Living Room,0.8,On,20,3\n"
+
+	records, err := EnergyRecords(TypedTable(testDataset(t, data)))
+	if err != nil {
+		t.Fatalf("EnergyRecords: %v", err)
+	}
+	if len(records) != 12 {
+		t.Fatalf("got %d records, want the 12 with a date and consumption", len(records))
+	}
+	if records[0].Appliance != "TV" || !records[0].Timestamp.Equal(at(1, 8)) || records[0].Temperature != nil {
+		t.Errorf("first record = %+v, want the 08:00 TV reading without a temperature", records[0])
+	}
+	heater := records[1]
+	if !heater.Timestamp.Equal(at(1, 13).Add(30*time.Minute)) || heater.Status != "On" || heater.KWh != 1.5 {
+		t.Errorf("second record = %+v, want the 13:30 heater reading", heater)
+	}
+	if heater.Temperature == nil || *heater.Temperature != 18 || heater.People == nil || *heater.People != 2 {
+		t.Errorf("heater features = %v, %v, want 18 and 2", heater.Temperature, heater.People)
+	}
+	if !records[11].Timestamp.Equal(at(3, 9)) {
+		t.Errorf("last record at %s, want records in time order", records[11].Timestamp)
+	}
+
+	_, err = EnergyRecords(TypedTable(testDataset(t, "Appliance,Room\nTV,Living Room\n")))
+	if !errors.Is(err, ErrNoEnergyData) {
+		t.Errorf("err = %v, want ErrNoEnergyData", err)
+	}
+}
@@ -0,0 +1,671 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"luma-backend/model"
+)
+
+const (
+	MethodAuto          = "auto"
+	MethodSeasonalNaive = "seasonal_naive"
+	MethodHoltWinters   = "holt_winters"
+	MethodRegression    = "linear_regression"
+	MethodMean          = "mean"
+)
+
+const (
+	DefaultForecastLevel = 0.95
+	// Readings are hourly and households run on a daily rhythm.
+	seasonLength = 24
+	holdoutHours = 24
+	// Only the most recent eight weeks are modelled, which keeps long
+	// exports within maxSeriesBuckets and the fit on current habits.
+	forecastHistoryHours = 8 * 7 * 24
+)
+
+var (
+	ErrInvalidForecastQuery = errors.New("invalid forecast query")
+	errMethodNotApplicable  = errors.New("not enough history for this method")
+)
+
+type ForecastQuery struct {
+	GroupBy     string
+	Horizon     string
+	Method      string
+	Level       *float64
+	Temperature *float64
+	People      *float64
+}
+
+// hourlyForecast is a per-hour mean and standard deviation for one series.
+type hourlyForecast struct {
+	mean         []float64
+	std          []float64
+	coefficients map[string]float64
+}
+
+// hourlyFeatures holds the household-wide Temperature and Number_of_People
+// for each hour, averaged over the readings taken in that hour.
+type hourlyFeatures struct {
+	from        time.Time
+	temperature []float64
+	people      []float64
+	known       []bool
+}
+
+func (s *EnergyService) Forecast(email, householdID string, query ForecastQuery) (model.Forecast, error) {
+	records, err := s.Records(email, householdID)
+	if err != nil {
+		return model.Forecast{}, err
+	}
+	return ForecastRecords(records, query)
+}
+
+// ForecastRecords predicts the next day (hourly points) or week (daily
+// points) of consumption for each appliance or room. With the auto method
+// every applicable method is scored on the last day of history and the one
+// with the lowest mean absolute error is used. Intervals assume independent
+// hourly errors when points and totals are summed. Only the last
+// forecastHistoryHours of records are used.
+func ForecastRecords(records []model.EnergyRecord, query ForecastQuery) (model.Forecast, error) {
+	if query.GroupBy == "" {
+		query.GroupBy = "appliance"
+	}
+	if query.Horizon == "" {
+		query.Horizon = "day"
+	}
+	if query.Method == "" {
+		query.Method = MethodAuto
+	}
+	level := DefaultForecastLevel
+	if query.Level != nil {
+		level = *query.Level
+	}
+	if query.GroupBy != "appliance" && query.GroupBy != "room" {
+		return model.Forecast{}, fmt.Errorf("%w: group_by must be appliance or room", ErrInvalidForecastQuery)
+	}
+	horizonHours := 24
+	switch query.Horizon {
+	case "day":
+	case "week":
+		horizonHours = 7 * 24
+	default:
+		return model.Forecast{}, fmt.Errorf("%w: horizon must be day or week", ErrInvalidForecastQuery)
+	}
+	switch query.Method {
+	case MethodAuto, MethodSeasonalNaive, MethodHoltWinters, MethodRegression, MethodMean:
+	default:
+		return model.Forecast{}, fmt.Errorf("%w: method must be auto, seasonal_naive, holt_winters, linear_regression or mean", ErrInvalidForecastQuery)
+	}
+	if level <= 0 || level >= 1 {
+		return model.Forecast{}, fmt.Errorf("%w: level must be between 0 and 1", ErrInvalidForecastQuery)
+	}
+	z := math.Sqrt2 * math.Erfinv(level)
+
+	result := model.Forecast{
+		GroupBy: query.GroupBy,
+		Horizon: query.Horizon,
+		Level:   level,
+		Unit:    "kWh",
+		Series:  []model.ForecastSeries{},
+	}
+
+	if len(records) == 0 {
+		return result, nil
+	}
+	from := bucketStart(records[len(records)-1].Timestamp, "hour").Add(-(forecastHistoryHours - 1) * time.Hour)
+	if first := records[0].Timestamp; first.After(from) {
+		from = first
+	}
+	hourly, err := SeriesFromRecords(records, SeriesQuery{GroupBy: query.GroupBy, Interval: "hour", From: from})
+	if err != nil {
+		return model.Forecast{}, err
+	}
+	if len(hourly.Series) == 0 {
+		return result, nil
+	}
+
+	start := hourly.To
+	result.LastObserved = records[len(records)-1].Timestamp
+	result.From = start
+	result.To = start.Add(time.Duration(horizonHours) * time.Hour)
+
+	history := featuresByHour(records, hourly.From, len(hourly.Series[0].Points))
+	future := futureFeatures(history, start, horizonHours, query)
+
+	totalMean, totalVariance := 0.0, 0.0
+	for _, series := range hourly.Series {
+		y := make([]float64, len(series.Points))
+		for i, point := range series.Points {
+			y[i] = point.KWh
+		}
+
+		method, forecast, mae, err := forecastSeries(query.Method, y, history, future, horizonHours)
+		if err != nil {
+			return model.Forecast{}, fmt.Errorf("%w: %s for %s: %v", ErrInvalidForecastQuery, query.Method, series.Key, err)
+		}
+
+		item := model.ForecastSeries{
+			Key:          series.Key,
+			Method:       method,
+			Coefficients: forecast.coefficients,
+		}
+		if mae != nil {
+			rounded := roundKWh(*mae)
+			item.ValidationMAE = &rounded
+		}
+		item.Points, item.Total = forecastPoints(forecast, start, query.Horizon, z)
+		result.Series = append(result.Series, item)
+
+		for i := range forecast.mean {
+			totalMean += math.Max(forecast.mean[i], 0)
+			totalVariance += forecast.std[i] * forecast.std[i]
+		}
+	}
+	result.Total = forecastInterval(start, totalMean, math.Sqrt(totalVariance), z)
+
+	sort.SliceStable(result.Series, func(i, j int) bool {
+		return result.Series[i].Total.KWh > result.Series[j].Total.KWh
+	})
+	return result, nil
+}
+
+var forecastKeywords = []struct {
+	horizon  string
+	keywords []string
+}{
+	{"week", []string{"next week", "coming week", "next 7 days", "minggu depan", "pekan depan", "seminggu ke depan", "7 hari ke depan"}},
+	{"day", []string{"tomorrow", "next day", "next 24 hours", "besok", "esok", "24 jam ke depan"}},
+}
+
+// forecastHorizon recognises questions about upcoming consumption, such as
+// "how much will I use tomorrow", and returns the horizon they ask about.
+func forecastHorizon(query string) string {
+	query = strings.ToLower(query)
+	for _, entry := range forecastKeywords {
+		for _, keyword := range entry.keywords {
+			if strings.Contains(query, keyword) {
+				return entry.horizon
+			}
+		}
+	}
+	return ""
+}
+
+func forecastNote(dataset model.Dataset, horizon string) (string, error) {
+	records, err := EnergyRecords(TypedTable(dataset))
+	if err != nil {
+		return "", err
+	}
+	forecast, err := ForecastRecords(records, ForecastQuery{Horizon: horizon})
+	if err != nil || len(forecast.Series) == 0 {
+		return "", err
+	}
+
+	period := "besok"
+	if horizon == "week" {
+		period = "minggu depan"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Perkiraan konsumsi %s (%s sampai %s): total %.2f kWh, interval %.0f%%: %.2f-%.2f kWh.\nPer peralatan:",
+		period, forecast.From.Format("2006-01-02 15:04"), forecast.To.Format("2006-01-02 15:04"),
+		forecast.Total.KWh, forecast.Level*100, forecast.Total.Lower, forecast.Total.Upper)
+	for _, series := range forecast.Series {
+		fmt.Fprintf(&b, " %s %.2f kWh (%.2f-%.2f, metode %s);", series.Key, series.Total.KWh, series.Total.Lower, series.Total.Upper, series.Method)
+	}
+	return strings.TrimSuffix(b.String(), ";"), nil
+}
+
+func forecastSeries(method string, y []float64, history, future hourlyFeatures, horizon int) (string, hourlyForecast, *float64, error) {
+	if method != MethodAuto {
+		forecast, err := runForecast(method, y, history, future, horizon)
+		return method, forecast, nil, err
+	}
+
+	best, bestMAE := "", math.Inf(1)
+	if len(y) >= seasonLength+holdoutHours {
+		train := len(y) - holdoutHours
+		trainHistory := history.slice(0, train)
+		// Score with the inputs a real forecast would have, recent averages
+		// rather than the observed hold-out values.
+		holdout := futureFeatures(trainHistory, history.from.Add(time.Duration(train)*time.Hour), holdoutHours, ForecastQuery{})
+		for _, candidate := range []string{MethodSeasonalNaive, MethodHoltWinters, MethodRegression} {
+			forecast, err := runForecast(candidate, y[:train], trainHistory, holdout, holdoutHours)
+			if err != nil {
+				continue
+			}
+			mae := 0.0
+			for i, predicted := range forecast.mean {
+				mae += math.Abs(math.Max(predicted, 0) - y[train+i])
+			}
+			mae /= holdoutHours
+			if mae < bestMAE {
+				best, bestMAE = candidate, mae
+			}
+		}
+	}
+
+	if best != "" {
+		forecast, err := runForecast(best, y, history, future, horizon)
+		if err == nil {
+			return best, forecast, &bestMAE, nil
+		}
+	}
+	for _, fallback := range []string{MethodSeasonalNaive, MethodRegression, MethodMean} {
+		forecast, err := runForecast(fallback, y, history, future, horizon)
+		if err == nil {
+			return fallback, forecast, nil, nil
+		}
+	}
+	return "", hourlyForecast{}, nil, errMethodNotApplicable
+}
+
+func runForecast(method string, y []float64, history, future hourlyFeatures, horizon int) (hourlyForecast, error) {
+	switch method {
+	case MethodSeasonalNaive:
+		return seasonalNaive(y, horizon)
+	case MethodHoltWinters:
+		return holtWinters(y, horizon)
+	case MethodRegression:
+		return regression(y, history, future, horizon)
+	default:
+		return meanForecast(y, horizon)
+	}
+}
+
+// seasonalNaive repeats the last day. Its error is the spread of day-to-day
+// changes at the same hour, growing with each further day ahead.
+func seasonalNaive(y []float64, horizon int) (hourlyForecast, error) {
+	n := len(y)
+	if n < seasonLength {
+		return hourlyForecast{}, errMethodNotApplicable
+	}
+
+	sigma := standardDeviation(y)
+	if n > seasonLength {
+		sse := 0.0
+		for t := seasonLength; t < n; t++ {
+			e := y[t] - y[t-seasonLength]
+			sse += e * e
+		}
+		sigma = math.Sqrt(sse / float64(n-seasonLength))
+	}
+
+	forecast := hourlyForecast{mean: make([]float64, horizon), std: make([]float64, horizon)}
+	for i := 0; i < horizon; i++ {
+		forecast.mean[i] = y[n-seasonLength+i%seasonLength]
+		forecast.std[i] = sigma * math.Sqrt(float64(i/seasonLength+1))
+	}
+	return forecast, nil
+}
+
+// holtWinters fits additive level, trend and daily seasonality, choosing the
+// smoothing parameters from a small grid by one-step-ahead squared error.
+func holtWinters(y []float64, horizon int) (hourlyForecast, error) {
+	m := seasonLength
+	if len(y) < 2*m {
+		return hourlyForecast{}, errMethodNotApplicable
+	}
+
+	type fit struct {
+		alpha, beta, gamma float64
+		level, trend       float64
+		season             []float64
+		sse                float64
+		count              int
+	}
+	var best *fit
+	for _, alpha := range []float64{0.1, 0.3, 0.5, 0.8} {
+		for _, beta := range []float64{0.01, 0.05, 0.1} {
+			for _, gamma := range []float64{0.05, 0.1, 0.3} {
+				level := mean(y[:m])
+				trend := (mean(y[m:2*m]) - level) / float64(m)
+				season := make([]float64, m)
+				for i := 0; i < m; i++ {
+					season[i] = y[i] - level
+				}
+
+				sse, count := 0.0, 0
+				for t := m; t < len(y); t++ {
+					e := y[t] - (level + trend + season[t%m])
+					sse += e * e
+					count++
+
+					newLevel := alpha*(y[t]-season[t%m]) + (1-alpha)*(level+trend)
+					trend = beta*(newLevel-level) + (1-beta)*trend
+					season[t%m] = gamma*(y[t]-newLevel) + (1-gamma)*season[t%m]
+					level = newLevel
+				}
+
+				if best == nil || sse < best.sse {
+					best = &fit{alpha, beta, gamma, level, trend, season, sse, count}
+				}
+			}
+		}
+	}
+
+	sigma2 := best.sse / float64(best.count)
+	forecast := hourlyForecast{
+		mean: make([]float64, horizon),
+		std:  make([]float64, horizon),
+		coefficients: map[string]float64{
+			"alpha": best.alpha,
+			"beta":  best.beta,
+			"gamma": best.gamma,
+		},
+	}
+	a, b, g := best.alpha, best.beta, best.gamma
+	for i := 0; i < horizon; i++ {
+		h := float64(i + 1)
+		k := float64(i / m)
+		forecast.mean[i] = best.level + h*best.trend + best.season[(len(y)+i)%m]
+		// Prediction variance of the additive Holt-Winters (ETS(A,A,A)) model.
+		variance := 1 + (h-1)*(a*a+a*b*h+b*b*h*(2*h-1)/6) + k*(g*(2*a+g)+b*g*float64(m)*(k+1))
+		forecast.std[i] = math.Sqrt(sigma2 * variance)
+	}
+	return forecast, nil
+}
+
+// regression fits kWh = b0 + b1*Temperature + b2*Number_of_People by least
+// squares over the hours where both are known. Features that never vary are
+// dropped. Future hours use the caller's values when given, otherwise the
+// recent average for that hour of day.
+func regression(y []float64, history, future hourlyFeatures, horizon int) (hourlyForecast, error) {
+	var rows [][2]float64
+	var targets []float64
+	for t := 0; t < len(y) && t < len(history.known); t++ {
+		if history.known[t] {
+			rows = append(rows, [2]float64{history.temperature[t], history.people[t]})
+			targets = append(targets, y[t])
+		}
+	}
+
+	names := []string{"temperature", "number_of_people"}
+	var columns []int
+	for c := range names {
+		for _, row := range rows {
+			if row[c] != rows[0][c] {
+				columns = append(columns, c)
+				break
+			}
+		}
+	}
+	p := len(columns) + 1
+	if len(columns) == 0 || len(rows) <= p+1 {
+		return hourlyForecast{}, errMethodNotApplicable
+	}
+
+	design := func(row [2]float64) []float64 {
+		x := []float64{1}
+		for _, c := range columns {
+			x = append(x, row[c])
+		}
+		return x
+	}
+
+	xtx := make([][]float64, p)
+	for i := range xtx {
+		xtx[i] = make([]float64, p)
+	}
+	xty := make([]float64, p)
+	for r, row := range rows {
+		x := design(row)
+		for i := 0; i < p; i++ {
+			xty[i] += x[i] * targets[r]
+			for j := 0; j < p; j++ {
+				xtx[i][j] += x[i] * x[j]
+			}
+		}
+	}
+
+	inverse, ok := invert(xtx)
+	if !ok {
+		return hourlyForecast{}, errMethodNotApplicable
+	}
+	beta := multiply(inverse, xty)
+
+	sse := 0.0
+	for r, row := range rows {
+		e := targets[r] - dot(design(row), beta)
+		sse += e * e
+	}
+	sigma2 := sse / float64(len(rows)-p)
+
+	forecast := hourlyForecast{
+		mean:         make([]float64, horizon),
+		std:          make([]float64, horizon),
+		coefficients: map[string]float64{"intercept": beta[0]},
+	}
+	for i, c := range columns {
+		forecast.coefficients[names[c]] = beta[i+1]
+	}
+	for i := 0; i < horizon; i++ {
+		x := design([2]float64{future.temperature[i], future.people[i]})
+		forecast.mean[i] = dot(x, beta)
+		forecast.std[i] = math.Sqrt(sigma2 * (1 + dot(x, multiply(inverse, x))))
+	}
+	return forecast, nil
+}
+
+func meanForecast(y []float64, horizon int) (hourlyForecast, error) {
+	if len(y) == 0 {
+		return hourlyForecast{}, errMethodNotApplicable
+	}
+
+	average := mean(y)
+	sigma := standardDeviation(y) * math.Sqrt(1+1/float64(len(y)))
+	forecast := hourlyForecast{mean: make([]float64, horizon), std: make([]float64, horizon)}
+	for i := 0; i < horizon; i++ {
+		forecast.mean[i] = average
+		forecast.std[i] = sigma
+	}
+	return forecast, nil
+}
+
+// forecastPoints reports hourly points for a day ahead and daily points for
+// a week ahead, plus the horizon total. Consumption cannot be negative, so
+// means and lower bounds are clamped at zero.
+func forecastPoints(forecast hourlyForecast, start time.Time, horizon string, z float64) ([]model.ForecastPoint, model.ForecastPoint) {
+	step := 1
+	if horizon == "week" {
+		step = 24
+	}
+
+	points := make([]model.ForecastPoint, 0, len(forecast.mean)/step)
+	totalMean, totalVariance := 0.0, 0.0
+	for i := 0; i < len(forecast.mean); i += step {
+		pointMean, pointVariance := 0.0, 0.0
+		for j := i; j < i+step && j < len(forecast.mean); j++ {
+			pointMean += math.Max(forecast.mean[j], 0)
+			pointVariance += forecast.std[j] * forecast.std[j]
+		}
+		points = append(points, forecastInterval(start.Add(time.Duration(i)*time.Hour), pointMean, math.Sqrt(pointVariance), z))
+		totalMean += pointMean
+		totalVariance += pointVariance
+	}
+	return points, forecastInterval(start, totalMean, math.Sqrt(totalVariance), z)
+}
+
+func forecastInterval(start time.Time, mean, std, z float64) model.ForecastPoint {
+	mean = math.Max(mean, 0)
+	return model.ForecastPoint{
+		Start: start,
+		KWh:   roundKWh(mean),
+		Lower: roundKWh(math.Max(mean-z*std, 0)),
+		Upper: roundKWh(mean + z*std),
+	}
+}
+
+func featuresByHour(records []model.EnergyRecord, from time.Time, hours int) hourlyFeatures {
+	features := hourlyFeatures{
+		from:        from,
+		temperature: make([]float64, hours),
+		people:      make([]float64, hours),
+		known:       make([]bool, hours),
+	}
+	counts := make([]int, hours)
+	for _, record := range records {
+		t := int(record.Timestamp.Sub(from) / time.Hour)
+		if t < 0 || t >= hours || record.Temperature == nil || record.People == nil {
+			continue
+		}
+		features.temperature[t] += *record.Temperature
+		features.people[t] += *record.People
+		counts[t]++
+	}
+	for t, count := range counts {
+		if count > 0 {
+			features.temperature[t] /= float64(count)
+			features.people[t] /= float64(count)
+			features.known[t] = true
+		}
+	}
+	return features
+}
+
+// futureFeatures fills in the regression inputs for the forecast hours.
+func futureFeatures(history hourlyFeatures, start time.Time, horizon int, query ForecastQuery) hourlyFeatures {
+	// The most recent week of readings.
+	const recentHours = 7 * 24
+
+	var byHour [24][]int
+	var all []int
+	for t := len(history.known) - 1; t >= 0 && len(all) < recentHours; t-- {
+		if history.known[t] {
+			hour := history.from.Add(time.Duration(t) * time.Hour).Hour()
+			byHour[hour] = append(byHour[hour], t)
+			all = append(all, t)
+		}
+	}
+	average := func(values []float64, indexes []int) float64 {
+		sum := 0.0
+		for _, t := range indexes {
+			sum += values[t]
+		}
+		return sum / float64(len(indexes))
+	}
+
+	future := hourlyFeatures{
+		from:        start,
+		temperature: make([]float64, horizon),
+		people:      make([]float64, horizon),
+		known:       make([]bool, horizon),
+	}
+	for i := 0; i < horizon; i++ {
+		indexes := byHour[start.Add(time.Duration(i)*time.Hour).Hour()]
+		if len(indexes) == 0 {
+			indexes = all
+		}
+		if len(indexes) > 0 {
+			future.temperature[i] = average(history.temperature, indexes)
+			future.people[i] = average(history.people, indexes)
+			future.known[i] = true
+		}
+		if query.Temperature != nil {
+			future.temperature[i] = *query.Temperature
+		}
+		if query.People != nil {
+			future.people[i] = *query.People
+		}
+	}
+	return future
+}
+
+func (f hourlyFeatures) slice(from, to int) hourlyFeatures {
+	return hourlyFeatures{
+		from:        f.from.Add(time.Duration(from) * time.Hour),
+		temperature: f.temperature[from:to],
+		people:      f.people[from:to],
+		known:       f.known[from:to],
+	}
+}
+
+// invert inverts a small square matrix by Gauss-Jordan elimination.
+func invert(matrix [][]float64) ([][]float64, bool) {
+	n := len(matrix)
+	augmented := make([][]float64, n)
+	for i := range matrix {
+		augmented[i] = make([]float64, 2*n)
+		copy(augmented[i], matrix[i])
+		augmented[i][n+i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(augmented[row][col]) > math.Abs(augmented[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(augmented[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		augmented[col], augmented[pivot] = augmented[pivot], augmented[col]
+
+		divisor := augmented[col][col]
+		for j := range augmented[col] {
+			augmented[col][j] /= divisor
+		}
+		for row := 0; row < n; row++ {
+			if row == col {
+				continue
+			}
+			factor := augmented[row][col]
+			for j := range augmented[row] {
+				augmented[row][j] -= factor * augmented[col][j]
+			}
+		}
+	}
+
+	inverse := make([][]float64, n)
+	for i := range augmented {
+		inverse[i] = augmented[i][n:]
+	}
+	return inverse, true
+}
+
+func multiply(matrix [][]float64, vector []float64) []float64 {
+	result := make([]float64, len(matrix))
+	for i, row := range matrix {
+		result[i] = dot(row, vector)
+	}
+	return result
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+func standardDeviation(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	average := mean(values)
+	sum := 0.0
+	for _, value := range values {
+		sum += (value - average) * (value - average)
+	}
+	return math.Sqrt(sum / float64(len(values)-1))
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"luma-backend/model"
+)
+
+// dailyPattern returns hourly readings for two appliances whose use repeats
+// every day: a refrigerator with a constant draw and a TV on in the evening.
+func dailyPattern(start time.Time, hours int) []model.EnergyRecord {
+	records := make([]model.EnergyRecord, 0, 2*hours)
+	for t := 0; t < hours; t++ {
+		timestamp := start.Add(time.Duration(t) * time.Hour)
+		tv := 0.0
+		if hour := timestamp.Hour(); hour >= 18 && hour < 23 {
+			tv = 0.8
+		}
+		records = append(records,
+			model.EnergyRecord{Timestamp: timestamp, Appliance: "Refrigerator", Room: "Kitchen", KWh: 0.2},
+			model.EnergyRecord{Timestamp: timestamp, Appliance: "TV", Room: "Living Room", KWh: tv},
+		)
+	}
+	return records
+}
+
+func forecastLevel(value float64) *float64 {
+	return &value
+}
+
+func TestForecastRecordsWithAYearOfHistory(t *testing.T) {
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	records := dailyPattern(start, 365*24)
+
+	forecast, err := ForecastRecords(records, ForecastQuery{Horizon: "day"})
+	if err != nil {
+		t.Fatalf("ForecastRecords: %v", err)
+	}
+	if want := start.AddDate(1, 0, 0); !forecast.From.Equal(want) {
+		t.Errorf("forecast starts %s, want %s", forecast.From, want)
+	}
+	if len(forecast.Series) != 2 {
+		t.Fatalf("got %d series, want 2", len(forecast.Series))
+	}
+	if total := forecast.Total.KWh; math.Abs(total-(24*0.2+5*0.8)) > 0.05 {
+		t.Errorf("total = %v kWh, want about %v", total, 24*0.2+5*0.8)
+	}
+}
+
+func TestForecastAutoScoresRegressionWithoutHoldoutFeatures(t *testing.T) {
+	// Consumption follows temperature exactly, and the last day is far hotter
+	// than any before it. Knowing that day's temperature would let regression
+	// score a perfect hold-out, which no real forecast can.
+	start := time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)
+	var records []model.EnergyRecord
+	for t := 0; t < 8*24; t++ {
+		temperature := 20 + float64(t/24) + float64(t%5)
+		if t >= 7*24 {
+			temperature = 40
+		}
+		people := 2.0
+		records = append(records, model.EnergyRecord{
+			Timestamp:   start.Add(time.Duration(t) * time.Hour),
+			Appliance:   "Air Conditioner",
+			KWh:         temperature / 10,
+			Temperature: &temperature,
+			People:      &people,
+		})
+	}
+
+	forecast, err := ForecastRecords(records, ForecastQuery{})
+	if err != nil {
+		t.Fatalf("ForecastRecords: %v", err)
+	}
+	mae := forecast.Series[0].ValidationMAE
+	if mae == nil {
+		t.Fatal("auto forecast reported no validation MAE")
+	}
+	if *mae < 0.5 {
+		t.Errorf("validation MAE = %v, want the error of a forecast made without the hold-out temperatures", *mae)
+	}
+}
+
+func TestForecastRecordsRejectsInvalidQueries(t *testing.T) {
+	records := dailyPattern(time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC), 3*24)
+	tests := []struct {
+		name  string
+		query ForecastQuery
+	}{
+		{"group by", ForecastQuery{GroupBy: "season"}},
+		{"horizon", ForecastQuery{Horizon: "month"}},
+		{"method", ForecastQuery{Method: "arima"}},
+		{"level above one", ForecastQuery{Level: forecastLevel(1.5)}},
+		{"negative level", ForecastQuery{Level: forecastLevel(-0.5)}},
+		{"zero level", ForecastQuery{Level: forecastLevel(0)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ForecastRecords(records, test.query); !errors.Is(err, ErrInvalidForecastQuery) {
+				t.Errorf("err = %v, want ErrInvalidForecastQuery", err)
+			}
+		})
+	}
+}
+
+func TestForecastRecordsMethods(t *testing.T) {
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	records := dailyPattern(start, 14*24)
+	tests := []struct {
+		method string
+		want   float64
+	}{
+		{MethodSeasonalNaive, 24*0.2 + 5*0.8},
+		{MethodHoltWinters, 24*0.2 + 5*0.8},
+		{MethodMean, 24*0.2 + 5*0.8},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			forecast, err := ForecastRecords(records, ForecastQuery{Method: test.method})
+			if err != nil {
+				t.Fatalf("ForecastRecords: %v", err)
+			}
+			if len(forecast.Series) != 2 || forecast.Series[0].Key != "Refrigerator" {
+				t.Fatalf("got %d series, want Refrigerator first and TV", len(forecast.Series))
+			}
+			for _, series := range forecast.Series {
+				if series.Method != test.method || len(series.Points) != 24 {
+					t.Errorf("%s: method %s with %d points, want %s with 24", series.Key, series.Method, len(series.Points), test.method)
+				}
+			}
+			if total := forecast.Total.KWh; math.Abs(total-test.want) > 0.05 {
+				t.Errorf("total = %v kWh, want about %v", total, test.want)
+			}
+		})
+	}
+}
+
+func TestForecastTotalIgnoresNegativePredictions(t *testing.T) {
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	records := dailyPattern(start, 7*24)
+	for t := 0; t < 7*24; t++ {
+		// A heater being used less every hour extrapolates below zero tomorrow.
+		records = append(records, model.EnergyRecord{
+			Timestamp: start.Add(time.Duration(t) * time.Hour),
+			Appliance: "Heater",
+			Room:      "Bedroom",
+			KWh:       5 - float64(t)*0.0295,
+		})
+	}
+
+	forecast, err := ForecastRecords(records, ForecastQuery{Method: MethodHoltWinters})
+	if err != nil {
+		t.Fatalf("ForecastRecords: %v", err)
+	}
+	sum := 0.0
+	for _, series := range forecast.Series {
+		sum += series.Total.KWh
+	}
+	if math.Abs(forecast.Total.KWh-sum) > 0.01 {
+		t.Errorf("total = %v kWh, want the sum of the series, %v", forecast.Total.KWh, sum)
+	}
+}
+
+func TestForecastRecordsWeekHasDailyPoints(t *testing.T) {
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	forecast, err := ForecastRecords(dailyPattern(start, 14*24), ForecastQuery{Horizon: "week", Method: MethodSeasonalNaive})
+	if err != nil {
+		t.Fatalf("ForecastRecords: %v", err)
+	}
+	if want := start.AddDate(0, 0, 21); !forecast.To.Equal(want) {
+		t.Errorf("forecast ends %s, want %s", forecast.To, want)
+	}
+	for _, series := range forecast.Series {
+		if len(series.Points) != 7 {
+			t.Fatalf("%s has %d points, want 7", series.Key, len(series.Points))
+		}
+		for i, point := range series.Points {
+			if want := start.AddDate(0, 0, 14+i); !point.Start.Equal(want) {
+				t.Errorf("%s point %d starts %s, want %s", series.Key, i, point.Start, want)
+			}
+		}
+	}
+}
+
+func TestSeasonalNaiveAndHoltWintersRepeatTheDay(t *testing.T) {
+	y := make([]float64, 5*seasonLength)
+	for i := range y {
+		y[i] = 1 + math.Sin(2*math.Pi*float64(i%seasonLength)/seasonLength)
+	}
+	methods := []struct {
+		name string
+		run  func([]float64, int) (hourlyForecast, error)
+	}{
+		{"seasonal naive", seasonalNaive},
+		{"holt winters", holtWinters},
+	}
+
+	for _, method := range methods {
+		t.Run(method.name, func(t *testing.T) {
+			forecast, err := method.run(y, 2*seasonLength)
+			if err != nil {
+				t.Fatalf("forecast: %v", err)
+			}
+			for i, got := range forecast.mean {
+				if want := y[i%seasonLength]; math.Abs(got-want) > 0.01 {
+					t.Errorf("hour %d = %v, want %v", i, got, want)
+				}
+			}
+			if _, err := method.run(y[:seasonLength-1], seasonLength); !errors.Is(err, errMethodNotApplicable) {
+				t.Errorf("short history err = %v, want errMethodNotApplicable", err)
+			}
+		})
+	}
+}
+
+func TestInvert(t *testing.T) {
+	inverse, ok := invert([][]float64{{4, 7}, {2, 6}})
+	if !ok {
+		t.Fatal("invert reported a singular matrix")
+	}
+	want := [][]float64{{0.6, -0.7}, {-0.2, 0.4}}
+	for i := range want {
+		for j := range want[i] {
+			if math.Abs(inverse[i][j]-want[i][j]) > 1e-9 {
+				t.Errorf("inverse[%d][%d] = %v, want %v", i, j, inverse[i][j], want[i][j])
+			}
+		}
+	}
+
+	if _, ok := invert([][]float64{{1, 2}, {2, 4}}); ok {
+		t.Error("invert accepted a singular matrix")
+	}
+}
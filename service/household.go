@@ -0,0 +1,253 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"luma-backend/model"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrHouseholdNotFound  = errors.New("household not found")
+	ErrAlreadyInHousehold = errors.New("already a member of a household")
+	ErrAlreadyMember      = errors.New("user is already a member or invited")
+	ErrMemberNotFound     = errors.New("member not found")
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrLastOwner          = errors.New("a household needs at least one owner")
+)
+
+// HouseholdStore persists households and their member lists.
+type HouseholdStore interface {
+	CreateHousehold(household model.Household) error
+	GetHousehold(householdID string) (*model.Household, error)
+	FindHouseholdByMember(email string) (*model.Household, error)
+	ListHouseholdsByMember(email string) ([]model.Household, error)
+	ListInvitations(email string) ([]model.Household, error)
+	AddMember(householdID string, member model.HouseholdMember) (bool, error)
+	UpdateMemberRole(householdID, email string, role model.HouseholdRole) (bool, error)
+	AcceptInvitation(householdID, email string) (*model.Household, error)
+	ReopenInvitation(householdID, email string) error
+	RemoveMember(householdID, email string) (bool, error)
+}
+
+type HouseholdService struct {
+	HouseholdRepo HouseholdStore
+}
+
+func (s *HouseholdService) Create(email, name string) (model.Household, error) {
+	email = normalizeEmail(email)
+	existing, err := s.HouseholdRepo.FindHouseholdByMember(email)
+	if err != nil {
+		return model.Household{}, err
+	}
+	if existing != nil {
+		return model.Household{}, ErrAlreadyInHousehold
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Household"
+	}
+
+	now := time.Now()
+	household := model.Household{
+		ID:   uuid.New().String(),
+		Name: name,
+		Members: []model.HouseholdMember{{
+			Email:     email,
+			Role:      model.RoleOwner,
+			Status:    model.MemberActive,
+			InvitedAt: now,
+			JoinedAt:  &now,
+		}},
+		CreatedAt: now,
+	}
+
+	err = s.HouseholdRepo.CreateHousehold(household)
+	if err != nil {
+		return model.Household{}, err
+	}
+	return household, nil
+}
+
+// Current returns the household email has joined, or nil for users who keep
+// their data to themselves.
+func (s *HouseholdService) Current(email string) (*model.Household, error) {
+	return s.HouseholdRepo.FindHouseholdByMember(normalizeEmail(email))
+}
+
+func (s *HouseholdService) Invitations(email string) ([]model.Household, error) {
+	return s.HouseholdRepo.ListInvitations(normalizeEmail(email))
+}
+
+// MemberEmails lists everyone whose history email may read: the user alone,
+// or every active member of their household.
+func (s *HouseholdService) MemberEmails(email string) ([]string, error) {
+	household, err := s.Current(email)
+	if err != nil {
+		return nil, err
+	}
+	if household == nil {
+		return []string{email}, nil
+	}
+	return household.ActiveEmails(), nil
+}
+
+func (s *HouseholdService) Invite(household model.Household, invitedBy, email string, role model.HouseholdRole) (model.HouseholdMember, error) {
+	email = normalizeEmail(email)
+	if role == "" {
+		role = model.RoleMember
+	}
+	if !role.Valid() {
+		return model.HouseholdMember{}, ErrInvalidRole
+	}
+
+	member := model.HouseholdMember{
+		Email:     email,
+		Role:      role,
+		Status:    model.MemberInvited,
+		InvitedBy: invitedBy,
+		InvitedAt: time.Now(),
+	}
+
+	added, err := s.HouseholdRepo.AddMember(household.ID, member)
+	if err != nil {
+		return model.HouseholdMember{}, err
+	}
+	if !added {
+		return model.HouseholdMember{}, ErrAlreadyMember
+	}
+	return member, nil
+}
+
+func (s *HouseholdService) UpdateRole(household model.Household, email string, role model.HouseholdRole) error {
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+	email = normalizeEmail(email)
+	member, ok := household.Member(email)
+	if !ok {
+		return ErrMemberNotFound
+	}
+	if member.Role == model.RoleOwner && role != model.RoleOwner && isLastOwner(household, email) {
+		return ErrLastOwner
+	}
+
+	found, err := s.HouseholdRepo.UpdateMemberRole(household.ID, email, role)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrMemberNotFound
+	}
+	return nil
+}
+
+// RemoveMember removes a member or withdraws an invitation. Members leave a
+// household the same way.
+func (s *HouseholdService) RemoveMember(household model.Household, email string) error {
+	email = normalizeEmail(email)
+	member, ok := household.Member(email)
+	if !ok {
+		return ErrMemberNotFound
+	}
+	if member.Role == model.RoleOwner && isLastOwner(household, email) {
+		return ErrLastOwner
+	}
+
+	found, err := s.HouseholdRepo.RemoveMember(household.ID, email)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrMemberNotFound
+	}
+	return nil
+}
+
+// AcceptInvitation claims the invitation atomically, so accepting it twice
+// succeeds once. Invitations from two households accepted at the same time
+// are both claimed, so the later join is undone afterwards.
+func (s *HouseholdService) AcceptInvitation(email, householdID string) error {
+	email = normalizeEmail(email)
+	existing, err := s.Current(email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return ErrAlreadyInHousehold
+	}
+
+	household, err := s.HouseholdRepo.AcceptInvitation(householdID, email)
+	if err != nil {
+		return err
+	}
+	if household == nil {
+		return ErrHouseholdNotFound
+	}
+
+	joined, err := s.HouseholdRepo.ListHouseholdsByMember(email)
+	if err != nil {
+		return err
+	}
+	if first := firstJoined(joined, email); first != nil && first.ID != household.ID {
+		if err := s.HouseholdRepo.ReopenInvitation(householdID, email); err != nil {
+			return err
+		}
+		return ErrAlreadyInHousehold
+	}
+	return nil
+}
+
+func (s *HouseholdService) DeclineInvitation(email, householdID string) error {
+	email = normalizeEmail(email)
+	household, err := s.HouseholdRepo.GetHousehold(householdID)
+	if err != nil {
+		return err
+	}
+	if household == nil {
+		return ErrHouseholdNotFound
+	}
+	member, ok := household.Member(email)
+	if !ok || member.Status != model.MemberInvited {
+		return ErrHouseholdNotFound
+	}
+
+	_, err = s.HouseholdRepo.RemoveMember(householdID, email)
+	return err
+}
+
+func isLastOwner(household model.Household, email string) bool {
+	for _, member := range household.Members {
+		if member.Email != email && member.Role == model.RoleOwner && member.Status == model.MemberActive {
+			return false
+		}
+	}
+	return true
+}
+
+// firstJoined picks the household email joined earliest, breaking ties by
+// ID, so racing acceptances agree on which one to keep.
+func firstJoined(households []model.Household, email string) *model.Household {
+	var first *model.Household
+	var firstAt time.Time
+	for i := range households {
+		member, ok := households[i].Member(email)
+		if !ok || member.JoinedAt == nil {
+			continue
+		}
+		if first == nil || member.JoinedAt.Before(firstAt) || (member.JoinedAt.Equal(firstAt) && households[i].ID < first.ID) {
+			first, firstAt = &households[i], *member.JoinedAt
+		}
+	}
+	return first
+}
+
+// normalizeEmail makes invitations match the address a user logs in with
+// regardless of case or stray whitespace.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"luma-backend/model"
+)
+
+// memoryHouseholdStore applies each update under one lock, as MongoDB does
+// for a single document.
+type memoryHouseholdStore struct {
+	mu         sync.Mutex
+	households map[string]model.Household
+}
+
+func newMemoryHouseholdStore() *memoryHouseholdStore {
+	return &memoryHouseholdStore{households: map[string]model.Household{}}
+}
+
+func (m *memoryHouseholdStore) CreateHousehold(household model.Household) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.households[household.ID] = household
+	return nil
+}
+
+func (m *memoryHouseholdStore) GetHousehold(householdID string) (*model.Household, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	household, ok := m.households[householdID]
+	if !ok {
+		return nil, nil
+	}
+	return &household, nil
+}
+
+func (m *memoryHouseholdStore) FindHouseholdByMember(email string) (*model.Household, error) {
+	households, _ := m.ListHouseholdsByMember(email)
+	if len(households) == 0 {
+		return nil, nil
+	}
+	return &households[0], nil
+}
+
+func (m *memoryHouseholdStore) ListHouseholdsByMember(email string) ([]model.Household, error) {
+	return m.withMember(email, model.MemberActive), nil
+}
+
+func (m *memoryHouseholdStore) ListInvitations(email string) ([]model.Household, error) {
+	return m.withMember(email, model.MemberInvited), nil
+}
+
+func (m *memoryHouseholdStore) withMember(email, status string) []model.Household {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	households := []model.Household{}
+	for _, household := range m.households {
+		if memberIndex(household, email, status) >= 0 {
+			households = append(households, household)
+		}
+	}
+	return households
+}
+
+func (m *memoryHouseholdStore) AddMember(householdID string, member model.HouseholdMember) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	household, ok := m.households[householdID]
+	if !ok || memberIndex(household, member.Email, "") >= 0 {
+		return false, nil
+	}
+	household.Members = append(household.Members, member)
+	m.households[householdID] = household
+	return true, nil
+}
+
+func (m *memoryHouseholdStore) UpdateMemberRole(householdID, email string, role model.HouseholdRole) (bool, error) {
+	return m.updateMember(householdID, email, "", func(member *model.HouseholdMember) { member.Role = role }) != nil, nil
+}
+
+func (m *memoryHouseholdStore) AcceptInvitation(householdID, email string) (*model.Household, error) {
+	return m.updateMember(householdID, email, model.MemberInvited, func(member *model.HouseholdMember) {
+		now := time.Now()
+		member.Status, member.JoinedAt = model.MemberActive, &now
+	}), nil
+}
+
+func (m *memoryHouseholdStore) ReopenInvitation(householdID, email string) error {
+	m.updateMember(householdID, email, model.MemberActive, func(member *model.HouseholdMember) {
+		member.Status, member.JoinedAt = model.MemberInvited, nil
+	})
+	return nil
+}
+
+func (m *memoryHouseholdStore) RemoveMember(householdID, email string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	household, ok := m.households[householdID]
+	i := memberIndex(household, email, "")
+	if !ok || i < 0 {
+		return false, nil
+	}
+	household.Members = append(household.Members[:i:i], household.Members[i+1:]...)
+	m.households[householdID] = household
+	return true, nil
+}
+
+func (m *memoryHouseholdStore) updateMember(householdID, email, status string, update func(*model.HouseholdMember)) *model.Household {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	household, ok := m.households[householdID]
+	i := memberIndex(household, email, status)
+	if !ok || i < 0 {
+		return nil
+	}
+	members := append([]model.HouseholdMember(nil), household.Members...)
+	update(&members[i])
+	household.Members = members
+	m.households[householdID] = household
+	return &household
+}
+
+// memberIndex matches emails exactly, as the MongoDB filters do.
+func memberIndex(household model.Household, email, status string) int {
+	for i, member := range household.Members {
+		if member.Email == email && (status == "" || member.Status == status) {
+			return i
+		}
+	}
+	return -1
+}
+
+func newTestHousehold(t *testing.T, households *HouseholdService, owner string) model.Household {
+	t.Helper()
+	household, err := households.Create(owner, "Home")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return household
+}
+
+func current(t *testing.T, households *HouseholdService, email string) model.Household {
+	t.Helper()
+	household, err := households.Current(email)
+	if err != nil || household == nil {
+		t.Fatalf("Current(%s) = %v, %v, want a household", email, household, err)
+	}
+	return *household
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	households := &HouseholdService{HouseholdRepo: newMemoryHouseholdStore()}
+	home := newTestHousehold(t, households, alice)
+
+	member, err := households.Invite(home, alice, "  Bob@Example.com ", model.RoleMember)
+	if err != nil {
+		t.Fatalf("Invite: %v", err)
+	}
+	if member.Email != bob {
+		t.Errorf("invited %q, want %q", member.Email, bob)
+	}
+	if _, err := households.Invite(current(t, households, alice), alice, "BOB@example.com", model.RoleViewer); !errors.Is(err, ErrAlreadyMember) {
+		t.Errorf("inviting bob again: err = %v, want ErrAlreadyMember", err)
+	}
+
+	invitations, _ := households.Invitations("Bob@example.com")
+	if len(invitations) != 1 {
+		t.Fatalf("bob has %d invitations, want 1", len(invitations))
+	}
+	if err := households.AcceptInvitation("BOB@EXAMPLE.COM", home.ID); err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+	if err := households.AcceptInvitation(bob, home.ID); !errors.Is(err, ErrAlreadyInHousehold) {
+		t.Errorf("accepting twice: err = %v, want ErrAlreadyInHousehold", err)
+	}
+	if emails, _ := households.MemberEmails(bob); len(emails) != 2 {
+		t.Errorf("MemberEmails(bob) = %v, want alice and bob", emails)
+	}
+
+	if err := households.AcceptInvitation(carol, home.ID); !errors.Is(err, ErrHouseholdNotFound) {
+		t.Errorf("accepting without an invitation: err = %v, want ErrHouseholdNotFound", err)
+	}
+}
+
+func TestAcceptInvitationOnce(t *testing.T) {
+	store := newMemoryHouseholdStore()
+	households := &HouseholdService{HouseholdRepo: store}
+	var homes []model.Household
+	for _, owner := range []string{alice, carol} {
+		home := newTestHousehold(t, households, owner)
+		if _, err := households.Invite(home, owner, bob, model.RoleMember); err != nil {
+			t.Fatalf("Invite: %v", err)
+		}
+		homes = append(homes, home)
+	}
+
+	// Race the same invitation against itself and against the other
+	// household's; bob must end up in exactly one household.
+	var wg sync.WaitGroup
+	results := make(chan error, 4)
+	for _, id := range []string{homes[0].ID, homes[0].ID, homes[1].ID, homes[1].ID} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			results <- households.AcceptInvitation(bob, id)
+		}(id)
+	}
+	wg.Wait()
+	close(results)
+
+	accepted := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			accepted++
+		case errors.Is(err, ErrAlreadyInHousehold), errors.Is(err, ErrHouseholdNotFound):
+		default:
+			t.Errorf("AcceptInvitation: %v", err)
+		}
+	}
+	joined, _ := store.ListHouseholdsByMember(bob)
+	if accepted != 1 || len(joined) != 1 {
+		t.Errorf("%d acceptances succeeded and bob is in %d households, want 1 and 1", accepted, len(joined))
+	}
+}
+
+func TestLastOwnerProtection(t *testing.T) {
+	households := &HouseholdService{HouseholdRepo: newMemoryHouseholdStore()}
+	home := newTestHousehold(t, households, alice)
+	if _, err := households.Invite(home, alice, bob, model.RoleOwner); err != nil {
+		t.Fatalf("Invite: %v", err)
+	}
+
+	// bob has only been invited, so alice is still the last active owner.
+	home = current(t, households, alice)
+	if err := households.UpdateRole(home, alice, model.RoleMember); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("demoting the last owner: err = %v, want ErrLastOwner", err)
+	}
+	if err := households.RemoveMember(home, "Alice@Example.com"); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("removing the last owner: err = %v, want ErrLastOwner", err)
+	}
+	if err := households.UpdateRole(home, alice, "admin"); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("unknown role: err = %v, want ErrInvalidRole", err)
+	}
+
+	if err := households.AcceptInvitation(bob, home.ID); err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+	home = current(t, households, alice)
+	if err := households.UpdateRole(home, alice, model.RoleViewer); err != nil {
+		t.Errorf("demoting one of two owners: %v", err)
+	}
+	home = current(t, households, bob)
+	if err := households.RemoveMember(home, bob); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("removing the remaining owner: err = %v, want ErrLastOwner", err)
+	}
+	if err := households.RemoveMember(home, alice); err != nil {
+		t.Errorf("removing a viewer: %v", err)
+	}
+}
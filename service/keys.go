@@ -0,0 +1,166 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"luma-backend/model"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// KeySet signs access tokens with one active RSA key and verifies them
+// against every key it knows, so tokens issued before a rotation stay valid
+// until the retired key is dropped from JWT_VERIFY_KEY_FILES.
+type KeySet struct {
+	signingID  string
+	signingKey *rsa.PrivateKey
+	publicKeys map[string]*rsa.PublicKey
+}
+
+// LoadKeySet reads the active key from JWT_SIGNING_KEY_FILE and retired keys
+// from the comma-separated JWT_VERIFY_KEY_FILES. An ephemeral key would log
+// everyone out on every restart and disagree between replicas, so one is
+// only generated when JWT_EPHEMERAL_KEY=true is set for local development.
+func LoadKeySet() (*KeySet, error) {
+	var signingKey *rsa.PrivateKey
+	if path := os.Getenv("JWT_SIGNING_KEY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		signingKey, err = jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	} else if os.Getenv("JWT_EPHEMERAL_KEY") == "true" {
+		log.Println("JWT_SIGNING_KEY_FILE not set; using an ephemeral signing key")
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		signingKey = key
+	} else {
+		return nil, fmt.Errorf("JWT_SIGNING_KEY_FILE environment variable not set; set JWT_EPHEMERAL_KEY=true to generate a throwaway key for local development")
+	}
+
+	keys := &KeySet{
+		signingID:  keyID(&signingKey.PublicKey),
+		signingKey: signingKey,
+		publicKeys: make(map[string]*rsa.PublicKey),
+	}
+	keys.publicKeys[keys.signingID] = &signingKey.PublicKey
+
+	for _, path := range strings.Split(os.Getenv("JWT_VERIFY_KEY_FILES"), ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			privateKey, privateErr := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if privateErr != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			publicKey = &privateKey.PublicKey
+		}
+		keys.publicKeys[keyID(publicKey)] = publicKey
+	}
+
+	return keys, nil
+}
+
+// minOAuthStateKeyBytes matches the SHA-256 block the state HMAC is keyed with.
+const minOAuthStateKeyBytes = 32
+
+// LoadOAuthStateKey reads the HMAC key that signs the OAuth state cookie from
+// OAUTH_STATE_SECRET. The cookie carries the PKCE verifier, so a missing key
+// is an error rather than an empty one; JWT_EPHEMERAL_KEY=true generates a
+// per-process key for local development, as it does for the signing key.
+func LoadOAuthStateKey() ([]byte, error) {
+	if secret := os.Getenv("OAUTH_STATE_SECRET"); secret != "" {
+		if len(secret) < minOAuthStateKeyBytes {
+			return nil, fmt.Errorf("OAUTH_STATE_SECRET must be at least %d bytes", minOAuthStateKeyBytes)
+		}
+		return []byte(secret), nil
+	}
+	if os.Getenv("JWT_EPHEMERAL_KEY") != "true" {
+		return nil, fmt.Errorf("OAUTH_STATE_SECRET environment variable not set; set JWT_EPHEMERAL_KEY=true to generate a throwaway key for local development")
+	}
+
+	log.Println("OAUTH_STATE_SECRET not set; using an ephemeral OAuth state key")
+	key := make([]byte, minOAuthStateKeyBytes)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (k *KeySet) Sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = k.signingID
+	return token.SignedString(k.signingKey)
+}
+
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if token.Method != jwt.SigningMethodRS256 {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	publicKey, ok := k.publicKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return publicKey, nil
+}
+
+func (k *KeySet) JWKS() model.JWKS {
+	ids := make([]string, 0, len(k.publicKeys))
+	for id := range k.publicKeys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := model.JWKS{Keys: make([]model.JWK, 0, len(ids))}
+	for _, id := range ids {
+		n, e := encodePublicKey(k.publicKeys[id])
+		jwks.Keys = append(jwks.Keys, model.JWK{Kty: "RSA", Use: "sig", Alg: "RS256", Kid: id, N: n, E: e})
+	}
+	return jwks
+}
+
+// keyID is the RFC 7638 thumbprint of the public key, so every service
+// derives the same kid from the same key without extra configuration.
+func keyID(publicKey *rsa.PublicKey) string {
+	n, e := encodePublicKey(publicKey)
+	canonical, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{E: e, Kty: "RSA", N: n})
+
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func encodePublicKey(publicKey *rsa.PublicKey) (string, string) {
+	n := base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	return n, e
+}
@@ -0,0 +1,52 @@
+package service
+
+import "testing"
+
+func TestLoadKeySetRequiresSigningKey(t *testing.T) {
+	t.Setenv("JWT_SIGNING_KEY_FILE", "")
+	t.Setenv("JWT_VERIFY_KEY_FILES", "")
+
+	t.Setenv("JWT_EPHEMERAL_KEY", "")
+	if _, err := LoadKeySet(); err == nil {
+		t.Error("LoadKeySet succeeded without JWT_SIGNING_KEY_FILE or JWT_EPHEMERAL_KEY")
+	}
+
+	t.Setenv("JWT_EPHEMERAL_KEY", "true")
+	keys, err := LoadKeySet()
+	if err != nil {
+		t.Fatalf("LoadKeySet with JWT_EPHEMERAL_KEY: %v", err)
+	}
+	if len(keys.JWKS().Keys) != 1 {
+		t.Errorf("JWKS has %d keys, want the ephemeral one", len(keys.JWKS().Keys))
+	}
+}
+
+func TestLoadOAuthStateKey(t *testing.T) {
+	t.Setenv("OAUTH_STATE_SECRET", "")
+	t.Setenv("JWT_EPHEMERAL_KEY", "")
+	if _, err := LoadOAuthStateKey(); err == nil {
+		t.Error("LoadOAuthStateKey succeeded without OAUTH_STATE_SECRET or JWT_EPHEMERAL_KEY")
+	}
+
+	t.Setenv("OAUTH_STATE_SECRET", "too short")
+	if _, err := LoadOAuthStateKey(); err == nil {
+		t.Error("LoadOAuthStateKey accepted a 9-byte secret")
+	}
+
+	t.Setenv("OAUTH_STATE_SECRET", "0123456789abcdef0123456789abcdef")
+	key, err := LoadOAuthStateKey()
+	if err != nil || string(key) != "0123456789abcdef0123456789abcdef" {
+		t.Errorf("LoadOAuthStateKey = %q, %v, want the configured secret", key, err)
+	}
+
+	t.Setenv("OAUTH_STATE_SECRET", "")
+	t.Setenv("JWT_EPHEMERAL_KEY", "true")
+	first, err := LoadOAuthStateKey()
+	if err != nil {
+		t.Fatalf("LoadOAuthStateKey with JWT_EPHEMERAL_KEY: %v", err)
+	}
+	second, _ := LoadOAuthStateKey()
+	if len(first) != minOAuthStateKeyBytes || string(first) == string(second) {
+		t.Errorf("ephemeral keys %x and %x, want two distinct %d-byte keys", first, second, minOAuthStateKeyBytes)
+	}
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"luma-backend/model"
+	"luma-backend/repository"
+)
+
+type PromptBudget struct {
+	MaxTokens         int
+	MaxTableTokens    int
+	MinRecentMessages int
+}
+
+var DefaultPromptBudget = PromptBudget{
+	MaxTokens:         32000,
+	MaxTableTokens:    12000,
+	MinRecentMessages: 4,
+}
+
+const maxFallbackSummaryChars = 4000
+
+// BuildPrompt assembles the recommendation prompt within the token budget.
+// Large tables are replaced by column statistics and an evenly spaced sample,
+// and turns that no longer fit are folded into the session's rolling summary.
+// The turn being answered is left out of the history: the provider adds the
+// query itself and the table-QA answer is given as a note.
+func (s *AIService) BuildPrompt(sessionID, query string, dataset model.Dataset) (model.Prompt, error) {
+	budget := s.Budget
+	if budget.MaxTokens == 0 {
+		budget = DefaultPromptBudget
+	}
+
+	history, err := s.ChatRepo.GetMessages(sessionID)
+	if err != nil {
+		return model.Prompt{}, err
+	}
+	history, answer := splitCurrentTurn(history, query)
+
+	summary, err := s.ChatRepo.GetSummary(sessionID)
+	if err != nil {
+		return model.Prompt{}, err
+	}
+
+	prompt := model.Prompt{Query: query, Table: dataset.Table}
+	tableTokens := estimateTableTokens(dataset.Table)
+	if tableTokens > budget.MaxTableTokens {
+		prompt.TableSummary = summarizeTable(TypedTable(dataset))
+		prompt.Table = sampleTable(dataset.Table, tableTokens, budget.MaxTableTokens-estimateTokens(prompt.TableSummary))
+		tableTokens = estimateTableTokens(prompt.Table) + estimateTokens(prompt.TableSummary)
+	}
+
+	prompt.Notes = s.promptNotes(query, dataset)
+	if answer != "" {
+		prompt.Notes = append(prompt.Notes, "Jawaban model tabel untuk pertanyaan ini: "+answer)
+	}
+	for _, note := range prompt.Notes {
+		tableTokens += estimateTokens(note)
+	}
+
+	remaining := budget.MaxTokens - tableTokens - estimateTokens(query) - estimateTokens(summary.Text)
+	cut := len(history)
+	for cut > 0 {
+		cost := estimateMessageTokens(history[cut-1])
+		if remaining-cost < 0 && len(history)-cut >= budget.MinRecentMessages {
+			break
+		}
+		remaining -= cost
+		cut--
+	}
+
+	if summary.Covered > len(history) {
+		summary = model.Summary{}
+	}
+
+	if cut > summary.Covered {
+		text, err := s.summarize(summary.Text, history[summary.Covered:cut])
+		if err != nil {
+			log.Println("Error summarising chat history:", err)
+		} else {
+			summary = model.Summary{Text: text, Covered: cut, UpdatedAt: time.Now()}
+			if err := s.ChatRepo.SaveSummary(sessionID, summary); err != nil {
+				log.Println("Error saving chat summary:", err)
+			}
+		}
+	}
+
+	if cut < summary.Covered {
+		cut = summary.Covered
+	}
+	prompt.History = history[cut:]
+	prompt.Summary = summary.Text
+
+	return prompt, nil
+}
+
+// splitCurrentTurn removes the stored user query and any table-QA answers
+// after it from the end of history, returning what is left and the answer.
+func splitCurrentTurn(history []model.Message, query string) ([]model.Message, string) {
+	end := len(history)
+	for end > 0 && history[end-1].Source == model.SourceTableQA {
+		end--
+	}
+	if end == 0 || history[end-1].Role != "user" || partsText(history[end-1].Parts) != query {
+		return history, ""
+	}
+
+	var answers []string
+	for _, message := range history[end:] {
+		if text := strings.TrimSpace(partsText(message.Parts)); text != "" {
+			answers = append(answers, text)
+		}
+	}
+	return history[:end-1], strings.Join(answers, "\n")
+}
+
+// promptNotes gathers computed context, such as what the data cost under the
+// household tariff or a forecast for questions about the future, that the
+// model should not have to work out itself.
+func (s *AIService) promptNotes(query string, dataset model.Dataset) []string {
+	var notes []string
+	if horizon := forecastHorizon(query); horizon != "" {
+		note, err := forecastNote(dataset, horizon)
+		switch {
+		case errors.Is(err, ErrNoEnergyData):
+		case err != nil:
+			log.Println("Error forecasting consumption for prompt:", err)
+		case note != "":
+			notes = append(notes, note)
+		}
+	}
+	if s.Costs != nil {
+		note, err := s.Costs.PromptNote(dataset)
+		switch {
+		case errors.Is(err, ErrNoEnergyData):
+		case err != nil:
+			log.Println("Error computing energy cost for prompt:", err)
+		case note != "":
+			notes = append(notes, note)
+		}
+	}
+	return notes
+}
+
+func (s *AIService) summarize(previous string, messages []model.Message) (string, error) {
+	if summarizer, ok := s.Recommender.(repository.Summarizer); ok {
+		return summarizer.Summarize(previous, messages)
+	}
+	return extractiveSummary(previous, messages), nil
+}
+
+// extractiveSummary keeps the user's earlier questions verbatim. It is used
+// when the recommendation provider cannot summarise on its own.
+func extractiveSummary(previous string, messages []model.Message) string {
+	var b strings.Builder
+	b.WriteString(previous)
+	for _, message := range messages {
+		if message.Role != "user" || len(message.Parts) == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("- Pengguna bertanya: " + truncateRunes(message.Parts[0].Text, 200))
+	}
+
+	summary := b.String()
+	if runes := []rune(summary); len(runes) > maxFallbackSummaryChars {
+		summary = string(runes[len(runes)-maxFallbackSummaryChars:])
+	}
+	return summary
+}
+
+// estimateTokens uses the common four-characters-per-token approximation,
+// which is close enough for budgeting without a tokenizer round trip.
+func estimateTokens(text string) int {
+	return (len([]rune(text)) + 3) / 4
+}
+
+func estimateMessageTokens(message model.Message) int {
+	tokens := 4
+	for _, part := range message.Parts {
+		tokens += estimateTokens(part.Text)
+	}
+	return tokens
+}
+
+func estimateTableTokens(table map[string][]string) int {
+	chars := 0
+	for column, values := range table {
+		chars += len(column) + 1
+		for _, value := range values {
+			chars += len(value) + 1
+		}
+	}
+	return (chars + 3) / 4
+}
+
+func sampleTable(table map[string][]string, tableTokens, budget int) map[string][]string {
+	rows := 0
+	for _, values := range table {
+		if len(values) > rows {
+			rows = len(values)
+		}
+	}
+	if rows == 0 || tableTokens <= 0 {
+		return table
+	}
+
+	keep := 0
+	if budget > 0 {
+		keep = rows * budget / tableTokens
+	}
+	if keep >= rows {
+		return table
+	}
+	if keep < 1 {
+		keep = 1
+	}
+
+	sampled := make(map[string][]string, len(table))
+	for column, values := range table {
+		sampled[column] = make([]string, 0, keep)
+		for i := 0; i < keep; i++ {
+			index := i * rows / keep
+			if index < len(values) {
+				sampled[column] = append(sampled[column], values[index])
+			} else {
+				sampled[column] = append(sampled[column], "")
+			}
+		}
+	}
+	return sampled
+}
+
+func summarizeTable(table model.TypedTable) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tabel berisi %d baris. Yang ditampilkan hanya sampel; gunakan statistik berikut untuk keseluruhan data:\n", len(table.Rows))
+
+	for i, column := range table.Schema.Columns {
+		switch column.Type {
+		case model.ColumnFloat, model.ColumnInt:
+			minimum, maximum, sum, count := math.Inf(1), math.Inf(-1), 0.0, 0
+			for _, row := range table.Rows {
+				if !row[i].Valid {
+					continue
+				}
+				minimum = math.Min(minimum, row[i].Number)
+				maximum = math.Max(maximum, row[i].Number)
+				sum += row[i].Number
+				count++
+			}
+			if count == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "- %s: min %g, max %g, rata-rata %.2f, total %.2f\n", column.Name, minimum, maximum, sum/float64(count), sum)
+		case model.ColumnDate, model.ColumnTime:
+			var first, last *model.Value
+			for _, row := range table.Rows {
+				value := row[i]
+				if !value.Valid {
+					continue
+				}
+				if first == nil || value.Time.Before(first.Time) {
+					first = &value
+				}
+				if last == nil || value.Time.After(last.Time) {
+					last = &value
+				}
+			}
+			if first != nil {
+				fmt.Fprintf(&b, "- %s: %s sampai %s\n", column.Name, first.Raw, last.Raw)
+			}
+		default:
+			counts := make(map[string]int)
+			for _, row := range table.Rows {
+				if row[i].Valid {
+					counts[row[i].Raw]++
+				}
+			}
+			values := make([]string, 0, len(counts))
+			for value := range counts {
+				values = append(values, value)
+			}
+			sort.Slice(values, func(a, b int) bool {
+				if counts[values[a]] != counts[values[b]] {
+					return counts[values[a]] > counts[values[b]]
+				}
+				return values[a] < values[b]
+			})
+			if len(values) > 5 {
+				values = values[:5]
+			}
+			top := make([]string, 0, len(values))
+			for _, value := range values {
+				top = append(top, fmt.Sprintf("%s (%d)", value, counts[value]))
+			}
+			fmt.Fprintf(&b, "- %s: %d nilai unik, terbanyak %s\n", column.Name, len(counts), strings.Join(top, ", "))
+		}
+	}
+
+	return b.String()
+}
+
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
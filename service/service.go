@@ -1,32 +1,165 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
 	"luma-backend/model"
 	"luma-backend/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type AIService struct {
-	Connector *repository.AIModelConnector
-	ChatRepo  *repository.ChatRepository
+	TableQA     repository.TableQAProvider
+	Recommender repository.RecommendationProvider
+	ChatRepo    repository.ChatStore
+	Costs       *CostService
+	Budget      PromptBudget
 }
 
-func (s *AIService) GetAIResponse(inputs model.Inputs, token string) (model.Response, error) {
-	return s.Connector.ConnectAIModel(inputs, token)
+// GetAIResponse asks the table-QA model and returns its answer together
+// with the assistant message to store for it.
+func (s *AIService) GetAIResponse(inputs model.Inputs) (model.Response, model.Message, error) {
+	start := time.Now()
+	response, err := s.TableQA.AnswerTable(inputs)
+	if err != nil {
+		return model.Response{}, model.Message{}, err
+	}
+
+	message := model.Message{
+		Role:      "assistant",
+		Parts:     []model.Part{{Text: response.Answer}},
+		Source:    model.SourceTableQA,
+		Model:     s.TableQA.ModelName(),
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	return response, message, nil
 }
 
-func (s *AIService) GetGeminiRecommendation(sessionID, query string, table map[string][]string, token string) (model.APIResponse, error) {
-	chatHistory, err := s.GetChatHistory(sessionID)
+// GetRecommendation returns the recommendation response and one assistant
+// message per candidate.
+func (s *AIService) GetRecommendation(sessionID, query string, dataset model.Dataset) (model.APIResponse, []model.Message, error) {
+	prompt, err := s.BuildPrompt(sessionID, query, dataset)
+	if err != nil {
+		return model.APIResponse{}, nil, err
+	}
+
+	start := time.Now()
+	response, err := s.Recommender.Recommend(prompt)
 	if err != nil {
-		return model.APIResponse{}, err
+		return model.APIResponse{}, nil, err
 	}
-	return s.Connector.GeminiRecommendationWithHistory(query, table, token, chatHistory)
+	latency := time.Since(start).Milliseconds()
+
+	messages := make([]model.Message, 0, len(response.Candidates))
+	for _, candidate := range response.Candidates {
+		messages = append(messages, s.recommendationMessage(response, candidate.Content.Parts, latency))
+	}
+	return response, messages, nil
 }
 
+// StreamRecommendation relays recommendation text through onChunk as it is
+// generated and returns the assembled message, which holds whatever was
+// produced even when the stream fails part way or ctx is cancelled.
+// Providers that cannot stream deliver their answer as one chunk.
+func (s *AIService) StreamRecommendation(ctx context.Context, sessionID, query string, dataset model.Dataset, onChunk func(string) error) (model.Message, error) {
+	prompt, err := s.BuildPrompt(sessionID, query, dataset)
+	if err != nil {
+		return model.Message{}, err
+	}
+
+	start := time.Now()
+	var response model.APIResponse
+	if streamer, ok := s.Recommender.(repository.StreamingRecommendationProvider); ok {
+		response, err = streamer.StreamRecommend(ctx, prompt, onChunk)
+	} else {
+		response, err = s.Recommender.Recommend(prompt)
+		if err == nil && len(response.Candidates) > 0 {
+			err = onChunk(partsText(response.Candidates[0].Content.Parts))
+		}
+	}
 
-func (s *AIService) SaveChatHistory(sessionID string, message model.Message) error {
-	return s.ChatRepo.SaveMessage(sessionID, message)
+	var parts []model.Part
+	if len(response.Candidates) > 0 {
+		parts = []model.Part{{Text: partsText(response.Candidates[0].Content.Parts)}}
+	}
+	return s.recommendationMessage(response, parts, time.Since(start).Milliseconds()), err
+}
+
+func (s *AIService) recommendationMessage(response model.APIResponse, parts []model.Part, latency int64) model.Message {
+	message := model.Message{
+		Role:      "assistant",
+		Parts:     parts,
+		Source:    model.SourceRecommendation,
+		Model:     s.Recommender.ModelName(),
+		LatencyMs: latency,
+	}
+	if response.ModelVersion != "" {
+		message.Model = response.ModelVersion
+	}
+	if usage := response.UsageMetadata; usage != nil {
+		message.Usage = &model.Usage{
+			PromptTokens:     usage.PromptTokenCount,
+			CompletionTokens: usage.CandidatesTokenCount,
+			TotalTokens:      usage.TotalTokenCount,
+		}
+	}
+	return message
+}
+
+// SaveChatHistory stores message, giving it an ID and timestamp if it has
+// none, and returns it as stored.
+func (s *AIService) SaveChatHistory(sessionID string, message model.Message) (model.Message, error) {
+	if message.ID == "" {
+		message.ID = primitive.NewObjectID().Hex()
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+	err := s.ChatRepo.SaveMessage(sessionID, message)
+	if err != nil {
+		return model.Message{}, err
+	}
+	return message, nil
 }
 
 func (s *AIService) GetChatHistory(sessionID string) ([]model.Message, error) {
 	return s.ChatRepo.GetMessages(sessionID)
 }
+
+// GetChatHistoryPage returns up to limit messages preceding the cursor, or
+// the most recent ones when before is empty.
+func (s *AIService) GetChatHistoryPage(sessionID, before string, limit int) (model.ChatHistoryPage, error) {
+	position := -1
+	if before != "" {
+		n, err := strconv.Atoi(before)
+		if err != nil || n < 0 {
+			return model.ChatHistoryPage{}, ErrInvalidCursor
+		}
+		position = n
+	}
+
+	messages, positions, hasMore, err := s.ChatRepo.GetMessagesPage(sessionID, position, limit)
+	if err != nil {
+		return model.ChatHistoryPage{}, err
+	}
+
+	page := model.ChatHistoryPage{Messages: messages, HasMore: hasMore}
+	if hasMore && len(positions) > 0 {
+		page.NextCursor = strconv.Itoa(positions[0])
+	}
+	return page, nil
+}
+
+func partsText(parts []model.Part) string {
+	var text string
+	for _, part := range parts {
+		text += part.Text
+	}
+	return text
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"luma-backend/model"
+	"luma-backend/repository"
+)
+
+const testCSV = `Date,Time,Appliance,Room,Energy_Consumption,Status
+2023-01-01,00:00,TV,Living Room,0.8,On
+2023-01-01,01:00,Heater,Bedroom,1.5,On
+2023-01-01,02:00,Light,Kitchen,0.1,Off
+`
+
+func TestChatTurnOffline(t *testing.T) {
+	chats := repository.NewMemoryChatRepository()
+	ai := &AIService{TableQA: &repository.FakeProvider{}, Recommender: &repository.FakeProvider{}, ChatRepo: chats}
+	dataset := testDataset(t, testCSV)
+	const sessionID = "session-1"
+	const query = "What is the total energy consumption?"
+
+	_, err := ai.SaveChatHistory(sessionID, model.Message{Role: "user", Parts: []model.Part{{Text: query}}, Source: model.SourceUser})
+	if err != nil {
+		t.Fatalf("save user message: %v", err)
+	}
+
+	response, answer, err := ai.GetAIResponse(model.Inputs{Table: dataset.Table, Query: query})
+	if err != nil {
+		t.Fatalf("GetAIResponse: %v", err)
+	}
+	computed := Aggregate(TypedTable(dataset), response)
+	if computed.Aggregator != "SUM" || computed.Value == nil || *computed.Value != 2.4 || !computed.Match {
+		t.Fatalf("computed = %+v, want matching SUM of 2.4", computed)
+	}
+	if _, err := ai.SaveChatHistory(sessionID, answer); err != nil {
+		t.Fatalf("save answer: %v", err)
+	}
+
+	recommendation, messages, err := ai.GetRecommendation(sessionID, query, dataset)
+	if err != nil {
+		t.Fatalf("GetRecommendation: %v", err)
+	}
+	if len(messages) != 1 || !strings.Contains(partsText(messages[0].Parts), "0 pesan sebelumnya") {
+		t.Fatalf("recommendation = %+v, want one offline answer with the current turn kept out of the history", recommendation)
+	}
+	if _, err := ai.SaveChatHistory(sessionID, messages[0]); err != nil {
+		t.Fatalf("save recommendation: %v", err)
+	}
+
+	page, err := ai.GetChatHistoryPage(sessionID, "", 50)
+	if err != nil {
+		t.Fatalf("GetChatHistoryPage: %v", err)
+	}
+	sources := []string{model.SourceUser, model.SourceTableQA, model.SourceRecommendation}
+	if len(page.Messages) != len(sources) {
+		t.Fatalf("history has %d messages, want %d", len(page.Messages), len(sources))
+	}
+	for i, message := range page.Messages {
+		if message.Source != sources[i] || message.ID == "" || message.CreatedAt.IsZero() {
+			t.Errorf("message %d = %+v, want source %s with an ID and timestamp", i, message, sources[i])
+		}
+	}
+}
+
+func TestBuildPromptMovesCurrentTurnIntoNotes(t *testing.T) {
+	chats := repository.NewMemoryChatRepository()
+	ai := &AIService{Recommender: &repository.FakeProvider{}, ChatRepo: chats}
+	const sessionID = "session-1"
+	turns := []model.Message{
+		{Role: "user", Parts: []model.Part{{Text: "Berapa pemakaian TV?"}}, Source: model.SourceUser},
+		{Role: "assistant", Parts: []model.Part{{Text: "0.8"}}, Source: model.SourceTableQA},
+		{Role: "assistant", Parts: []model.Part{{Text: "Matikan TV saat tidak ditonton."}}, Source: model.SourceRecommendation},
+		{Role: "user", Parts: []model.Part{{Text: "Berapa total energi?"}}, Source: model.SourceUser},
+		{Role: "assistant", Parts: []model.Part{{Text: "SUM > 2.4"}}, Source: model.SourceTableQA},
+	}
+	for _, message := range turns {
+		if _, err := ai.SaveChatHistory(sessionID, message); err != nil {
+			t.Fatalf("SaveChatHistory: %v", err)
+		}
+	}
+
+	prompt, err := ai.BuildPrompt(sessionID, "Berapa total energi?", testDataset(t, testCSV))
+	if err != nil {
+		t.Fatalf("BuildPrompt: %v", err)
+	}
+	if len(prompt.History) != 3 || partsText(prompt.History[2].Parts) != "Matikan TV saat tidak ditonton." {
+		t.Errorf("history = %+v, want only the earlier turn", prompt.History)
+	}
+	if n := len(prompt.Notes); n == 0 || !strings.HasSuffix(prompt.Notes[n-1], "SUM > 2.4") {
+		t.Errorf("notes = %q, want the table-QA answer last", prompt.Notes)
+	}
+}
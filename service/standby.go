@@ -0,0 +1,183 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"luma-backend/model"
+)
+
+// idleFraction is how far below its usual "On" draw an appliance has to fall
+// to count as idling rather than working.
+const idleFraction = 0.25
+
+const monthHours = 30 * 24
+
+var standbyTips = []struct {
+	keywords []string
+	tip      string
+}{
+	{[]string{"tv", "television", "monitor", "console"}, "Switch it off at the wall or use a switched power strip, and turn off quick-start or standby features in its settings."},
+	{[]string{"refrigerator", "fridge", "freezer"}, "A refrigerator should not report power while off; check the door seal and thermostat, and whether the status sensor is reporting correctly."},
+	{[]string{"heater", "air conditioner", "ac", "fan"}, "Check the thermostat and timer; a heating or cooling unit drawing power while off may have a faulty switch or a fan that keeps running."},
+	{[]string{"light", "lamp", "bulb"}, "Smart bulbs and LED drivers draw power even when dark; use a wall switch or schedule them off."},
+	{[]string{"charger", "computer", "laptop", "router"}, "Unplug chargers and put computers to sleep or hibernate when not in use."},
+}
+
+// Standby finds appliances drawing energy while "Off", or idling far below
+// their usual "On" draw, in [from, to), and ranks them by what that waste
+// cost under the household tariff.
+func (s *CostService) Standby(email, householdID string, from, to time.Time) (model.StandbyReport, error) {
+	records, err := s.Energy.Records(email, householdID)
+	if err != nil {
+		return model.StandbyReport{}, err
+	}
+	tariff, err := s.GetTariff(email, householdID)
+	if err != nil {
+		return model.StandbyReport{}, err
+	}
+	return DetectStandby(tariff, records, from, to), nil
+}
+
+// DetectStandby looks for waste in the part of a time-ordered history that
+// falls in [from, to). Typical draws and prices come from the whole history,
+// so tiered blocks are filled by everything used earlier in the month.
+func DetectStandby(tariff model.Tariff, history []model.EnergyRecord, from, to time.Time) model.StandbyReport {
+	report := model.StandbyReport{Currency: tariff.Currency, Findings: []model.StandbyFinding{}}
+	priced := costRecords(tariff, history)
+	var window []int
+	for i, record := range history {
+		if (from.IsZero() || !record.Timestamp.Before(from)) && (to.IsZero() || record.Timestamp.Before(to)) {
+			window = append(window, i)
+		}
+	}
+	if len(window) == 0 {
+		return report
+	}
+	report.From = history[window[0]].Timestamp
+	report.To = history[window[len(window)-1]].Timestamp.Add(readingInterval)
+	scale := monthHours / report.To.Sub(report.From).Hours()
+
+	onDraw := map[string][]float64{}
+	for _, record := range history {
+		if isOn(record.Status) && record.KWh > 0 {
+			key := strings.ToLower(record.Appliance)
+			onDraw[key] = append(onDraw[key], record.KWh)
+		}
+	}
+	typical := map[string]float64{}
+	for key, values := range onDraw {
+		if len(values) >= minFallbackSamples {
+			typical[key] = median(values)
+		}
+	}
+
+	findings := map[string]*model.StandbyFinding{}
+	usage := map[string]float64{}
+	for _, i := range window {
+		record := history[i]
+		key := strings.ToLower(record.Appliance)
+		usage[key] += record.KWh
+		if record.KWh <= 0 {
+			continue
+		}
+
+		off := isOff(record.Status)
+		idle := isOn(record.Status) && typical[key] > 0 && record.KWh <= idleFraction*typical[key]
+		if !off && !idle {
+			continue
+		}
+
+		finding := findings[key]
+		if finding == nil {
+			finding = &model.StandbyFinding{Appliance: record.Appliance, Room: record.Room}
+			findings[key] = finding
+		}
+		if off {
+			finding.OffReadings++
+		} else {
+			finding.IdleReadings++
+		}
+		finding.WastedKWh += record.KWh
+		finding.WastedCost += priced[i].Cost
+	}
+
+	for key, finding := range findings {
+		if usage[key] > 0 {
+			finding.ShareOfUsage = roundKWh(finding.WastedKWh / usage[key])
+		}
+		finding.ProjectedMonthlyKWh = roundKWh(finding.WastedKWh * scale)
+		finding.ProjectedMonthlyCost = roundMoney(finding.WastedCost * scale)
+		report.TotalWastedKWh += finding.WastedKWh
+		report.TotalWastedCost += finding.WastedCost
+		report.ProjectedMonthlyCost += finding.WastedCost * scale
+
+		finding.WastedKWh = roundKWh(finding.WastedKWh)
+		finding.WastedCost = roundMoney(finding.WastedCost)
+		finding.Recommendation = standbyRecommendation(*finding, tariff.Currency)
+		report.Findings = append(report.Findings, *finding)
+	}
+	report.TotalWastedKWh = roundKWh(report.TotalWastedKWh)
+	report.TotalWastedCost = roundMoney(report.TotalWastedCost)
+	report.ProjectedMonthlyCost = roundMoney(report.ProjectedMonthlyCost)
+
+	sort.Slice(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.WastedCost != b.WastedCost {
+			return a.WastedCost > b.WastedCost
+		}
+		if a.WastedKWh != b.WastedKWh {
+			return a.WastedKWh > b.WastedKWh
+		}
+		return a.Appliance < b.Appliance
+	})
+	for i := range report.Findings {
+		report.Findings[i].Rank = i + 1
+	}
+	return report
+}
+
+func standbyRecommendation(finding model.StandbyFinding, currency string) string {
+	var what []string
+	if finding.OffReadings > 0 {
+		what = append(what, fmt.Sprintf("while switched off in %d readings", finding.OffReadings))
+	}
+	if finding.IdleReadings > 0 {
+		what = append(what, fmt.Sprintf("while idling in %d readings", finding.IdleReadings))
+	}
+
+	recommendation := fmt.Sprintf("%s in the %s used %.2f kWh (%s %.2f) %s, about %.2f kWh (%s %.2f) a month at this rate.",
+		finding.Appliance, finding.Room, finding.WastedKWh, currency, finding.WastedCost, strings.Join(what, " and "),
+		finding.ProjectedMonthlyKWh, currency, finding.ProjectedMonthlyCost)
+
+	appliance := strings.ToLower(finding.Appliance)
+	for _, entry := range standbyTips {
+		for _, keyword := range entry.keywords {
+			if containsWord(appliance, keyword) {
+				return recommendation + " " + entry.tip
+			}
+		}
+	}
+	return recommendation + " Unplug it when not in use or put it on a smart plug with a schedule."
+}
+
+func containsWord(text, word string) bool {
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool { return r == ' ' || r == '_' || r == '-' }) {
+		if field == word {
+			return true
+		}
+	}
+	return strings.Contains(word, " ") && strings.Contains(text, word)
+}
+
+func isOn(status string) bool {
+	status = strings.ToLower(strings.TrimSpace(status))
+	return status == "on" || status == "nyala" || status == "1" || status == "true"
+}
+
+func isOff(status string) bool {
+	status = strings.ToLower(strings.TrimSpace(status))
+	return status == "off" || status == "mati" || status == "0" || status == "false" || status == "standby"
+}
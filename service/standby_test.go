@@ -0,0 +1,134 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"luma-backend/model"
+)
+
+func TestDetectStandbyPricesWasteAgainstTheWholeMonth(t *testing.T) {
+	tariff := model.Tariff{
+		Type:     model.TariffTiered,
+		Currency: "IDR",
+		Tiers:    []model.TariffTier{{UpToKWh: kwhLimit(100), Rate: 1}, {Rate: 10}},
+	}
+	start := time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC)
+	history := []model.EnergyRecord{
+		{Timestamp: start, Appliance: "Heater", Status: "On", KWh: 150},
+		{Timestamp: start.AddDate(0, 0, 20), Appliance: "TV", Status: "Off", KWh: 2},
+	}
+
+	for _, from := range []time.Time{{}, start.AddDate(0, 0, 10)} {
+		report := DetectStandby(tariff, history, from, time.Time{})
+		if len(report.Findings) != 1 || report.Findings[0].WastedCost != 20 {
+			t.Errorf("from %s: findings = %+v, want the TV's 2 kWh at the second tier rate of 10", from, report.Findings)
+		}
+	}
+}
+
+func TestDetectStandby(t *testing.T) {
+	tariff := model.Tariff{Type: model.TariffFlat, Currency: "IDR", FlatRate: 1000}
+	start := time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC)
+	reading := func(hour int, appliance, status string, kwh float64) model.EnergyRecord {
+		return model.EnergyRecord{Timestamp: start.Add(time.Duration(hour) * time.Hour), Appliance: appliance, Room: "Living Room", Status: status, KWh: kwh}
+	}
+	type want struct {
+		appliance string
+		off, idle int
+		kwh       float64
+	}
+
+	tests := []struct {
+		name    string
+		history []model.EnergyRecord
+		want    []want
+	}{
+		{
+			"off readings with energy",
+			[]model.EnergyRecord{reading(0, "TV", "On", 0.8), reading(1, "TV", "Off", 0.05), reading(2, "TV", "standby", 0.05), reading(3, "TV", "Off", 0)},
+			[]want{{"TV", 2, 0, 0.1}},
+		},
+		{
+			"idle well below the usual on draw",
+			[]model.EnergyRecord{reading(0, "Computer", "On", 1), reading(1, "Computer", "On", 1), reading(2, "Computer", "On", 1), reading(3, "Computer", "On", 0.1)},
+			[]want{{"Computer", 0, 1, 0.1}},
+		},
+		{
+			"too few on readings to judge idling",
+			[]model.EnergyRecord{reading(0, "Computer", "On", 1), reading(1, "Computer", "On", 0.1)},
+			nil,
+		},
+		{
+			"ranked by wasted cost",
+			[]model.EnergyRecord{reading(0, "Lamp", "Off", 0.01), reading(1, "Heater", "Off", 0.5), reading(2, "TV", "Off", 0.1)},
+			[]want{{"Heater", 1, 0, 0.5}, {"TV", 1, 0, 0.1}, {"Lamp", 1, 0, 0.01}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := DetectStandby(tariff, test.history, time.Time{}, time.Time{})
+			if len(report.Findings) != len(test.want) {
+				t.Fatalf("got %d findings, want %d", len(report.Findings), len(test.want))
+			}
+			for i, want := range test.want {
+				got := report.Findings[i]
+				if got.Rank != i+1 || got.Appliance != want.appliance || got.OffReadings != want.off || got.IdleReadings != want.idle || got.WastedKWh != want.kwh {
+					t.Errorf("finding %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectStandbyProjectsAMonth(t *testing.T) {
+	tariff := model.Tariff{Type: model.TariffFlat, Currency: "IDR", FlatRate: 1000}
+	start := time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC)
+	history := []model.EnergyRecord{
+		{Timestamp: start, Appliance: "TV", Room: "Living Room", Status: "On", KWh: 0.8},
+		{Timestamp: start.Add(10 * time.Hour), Appliance: "TV", Room: "Living Room", Status: "Off", KWh: 0.1},
+		{Timestamp: start.Add(239 * time.Hour), Appliance: "TV", Room: "Living Room", Status: "On", KWh: 1.1},
+	}
+
+	report := DetectStandby(tariff, history, time.Time{}, time.Time{})
+	if want := start.Add(240 * time.Hour); !report.From.Equal(start) || !report.To.Equal(want) {
+		t.Errorf("report covers %s to %s, want %s to %s", report.From, report.To, start, want)
+	}
+	if len(report.Findings) != 1 {
+		t.Fatalf("got %d findings, want 1", len(report.Findings))
+	}
+	finding := report.Findings[0]
+	// Ten days of data scale to a 30-day month by three.
+	if finding.ProjectedMonthlyKWh != 0.3 || finding.ProjectedMonthlyCost != 300 || report.ProjectedMonthlyCost != 300 {
+		t.Errorf("projected %v kWh, %v and %v in total, want 0.3 kWh and 300", finding.ProjectedMonthlyKWh, finding.ProjectedMonthlyCost, report.ProjectedMonthlyCost)
+	}
+	if finding.ShareOfUsage != 0.05 {
+		t.Errorf("share of usage = %v, want 0.05", finding.ShareOfUsage)
+	}
+	if report.TotalWastedKWh != 0.1 || report.TotalWastedCost != 100 {
+		t.Errorf("total waste = %v kWh costing %v, want 0.1 kWh costing 100", report.TotalWastedKWh, report.TotalWastedCost)
+	}
+}
+
+func TestStandbyRecommendation(t *testing.T) {
+	tests := []struct {
+		appliance string
+		want      string
+	}{
+		{"Smart TV", "power strip"},
+		{"Chest Freezer", "door seal"},
+		{"Air Conditioner", "thermostat and timer"},
+		{"Desk Lamp", "wall switch"},
+		{"Aquarium Pump", "smart plug"},
+	}
+
+	for _, test := range tests {
+		finding := model.StandbyFinding{Appliance: test.appliance, Room: "Kitchen", OffReadings: 2, IdleReadings: 1}
+		recommendation := standbyRecommendation(finding, "IDR")
+		if !strings.Contains(recommendation, test.want) || !strings.Contains(recommendation, "switched off in 2 readings and while idling in 1 readings") {
+			t.Errorf("%s: recommendation = %q, want it to mention %q and both kinds of waste", test.appliance, recommendation, test.want)
+		}
+	}
+}